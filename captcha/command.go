@@ -0,0 +1,55 @@
+// captcha/command.go
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandSolver 调用本地离线识别程序（如 ddddocr 脚本）：验证码图片从 stdin 传入，识别结果从 stdout 读取
+type commandSolver struct {
+	args []string // 程序路径及参数，构建识别后端链时已保证非空
+}
+
+func (s *commandSolver) Name() string {
+	return "local"
+}
+
+func (s *commandSolver) Solve(base64Image string) (string, error) {
+	img, err := decodeImage(base64Image)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.args[0], s.args[1:]...)
+	cmd.Stdin = bytes.NewReader(img)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("本地识别程序执行失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// decodeImage 将 base64（可带 data URI 前缀）解码为图片字节
+func decodeImage(base64Image string) ([]byte, error) {
+	if i := strings.Index(base64Image, ","); strings.HasPrefix(base64Image, "data:") && i >= 0 {
+		base64Image = base64Image[i+1:]
+	}
+	img, err := base64.StdEncoding.DecodeString(base64Image)
+	if err != nil {
+		return nil, fmt.Errorf("验证码图片解码失败: %v", err)
+	}
+	return img, nil
+}
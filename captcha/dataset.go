@@ -0,0 +1,51 @@
+// captcha/dataset.go
+package captcha
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSamplesDir 读取目录下的已标注验证码图片，文件名格式为 <验证码文本>_<任意后缀>.png（或 .jpg/.gif）
+func LoadSamplesDir(dir string) ([]LabeledSample, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []LabeledSample
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if ext != ".png" && ext != ".jpg" && ext != ".jpeg" && ext != ".gif" {
+			continue
+		}
+
+		label := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		if i := strings.Index(label, "_"); i >= 0 {
+			label = label[:i]
+		}
+
+		img, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, LabeledSample{Label: label, Image: img})
+	}
+	return samples, nil
+}
+
+// SplitSamples 按比例随机划分训练集与留出集
+func SplitSamples(samples []LabeledSample, holdout float64, seed int64) (train, test []LabeledSample) {
+	shuffled := append([]LabeledSample(nil), samples...)
+	rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	n := int(float64(len(shuffled)) * holdout)
+	return shuffled[n:], shuffled[:n]
+}
@@ -0,0 +1,106 @@
+// captcha/manual.go
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PendingCaptcha 等待人工识别的验证码
+type PendingCaptcha struct {
+	ID        string    `json:"id"`
+	Image     string    `json:"image"` // base64 图像（带 data URI 前缀）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type manualRequest struct {
+	PendingCaptcha
+	answer chan string
+}
+
+var (
+	manualQueue   = make(map[string]*manualRequest)
+	manualQueueMu sync.Mutex
+)
+
+// manualSolver 人工识别：把验证码放入待处理队列，等待管理员在后台填写答案
+type manualSolver struct {
+	timeout time.Duration
+}
+
+func (s *manualSolver) Name() string {
+	return "manual"
+}
+
+func (s *manualSolver) Solve(base64Image string) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+
+	req := &manualRequest{
+		PendingCaptcha: PendingCaptcha{
+			ID:        hex.EncodeToString(idBytes),
+			Image:     base64Image,
+			CreatedAt: time.Now(),
+		},
+		answer: make(chan string, 1),
+	}
+
+	manualQueueMu.Lock()
+	manualQueue[req.ID] = req
+	manualQueueMu.Unlock()
+
+	defer func() {
+		manualQueueMu.Lock()
+		delete(manualQueue, req.ID)
+		manualQueueMu.Unlock()
+	}()
+
+	log.Printf("✋ 验证码 %s 等待人工识别（最长 %s）", req.ID, s.timeout)
+
+	select {
+	case text := <-req.answer:
+		return text, nil
+	case <-time.After(s.timeout):
+		return "", errors.New("等待人工识别超时")
+	}
+}
+
+// PendingManual 返回所有等待人工识别的验证码，按创建时间排序
+func PendingManual() []PendingCaptcha {
+	manualQueueMu.Lock()
+	defer manualQueueMu.Unlock()
+
+	list := make([]PendingCaptcha, 0, len(manualQueue))
+	for _, req := range manualQueue {
+		list = append(list, req.PendingCaptcha)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// AnswerManual 提交人工识别结果
+func AnswerManual(id, text string) error {
+	manualQueueMu.Lock()
+	req, ok := manualQueue[id]
+	manualQueueMu.Unlock()
+
+	if !ok {
+		return errors.New("验证码不存在或已超时")
+	}
+
+	select {
+	case req.answer <- text:
+		return nil
+	default:
+		return errors.New("该验证码已被识别")
+	}
+}
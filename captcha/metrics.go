@@ -0,0 +1,131 @@
+// captcha/metrics.go
+package captcha
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// statDelta 一次统计增量
+type statDelta struct {
+	attempts  int
+	successes int
+	errors    int
+	accepted  int
+	rejected  int
+	latencyMs int64
+	cost      float64
+}
+
+// addStat 把增量累加到当天该后端的统计行
+func addStat(solver string, d statDelta) {
+	stat := database.CaptchaStat{
+		Day:       time.Now().Format("2006-01-02"),
+		Solver:    solver,
+		Attempts:  d.attempts,
+		Successes: d.successes,
+		Errors:    d.errors,
+		Accepted:  d.accepted,
+		Rejected:  d.rejected,
+		LatencyMs: d.latencyMs,
+		Cost:      d.cost,
+	}
+
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "solver"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + ?", d.attempts),
+			"successes":  gorm.Expr("successes + ?", d.successes),
+			"errors":     gorm.Expr("errors + ?", d.errors),
+			"accepted":   gorm.Expr("accepted + ?", d.accepted),
+			"rejected":   gorm.Expr("rejected + ?", d.rejected),
+			"latency_ms": gorm.Expr("latency_ms + ?", d.latencyMs),
+			"cost":       gorm.Expr("cost + ?", d.cost),
+		}),
+	}).Create(&stat).Error
+	if err != nil {
+		log.Printf("⚠️ 更新验证码统计失败: %v", err)
+	}
+}
+
+// recordAttempt 记录一次后端调用的耗时与费用
+func recordAttempt(solver string, latency time.Duration, err error) {
+	d := statDelta{
+		attempts:  1,
+		latencyMs: latency.Milliseconds(),
+		cost:      config.CaptchaCosts[solver],
+	}
+	if err != nil {
+		d.errors = 1
+	} else {
+		d.successes = 1
+	}
+	addStat(solver, d)
+}
+
+// recordOutcome 记录平台是否接受了某个后端的识别结果
+func recordOutcome(solver string, accepted *bool) {
+	if solver == "" || accepted == nil {
+		return
+	}
+	if *accepted {
+		addStat(solver, statDelta{accepted: 1})
+	} else {
+		addStat(solver, statDelta{rejected: 1})
+	}
+}
+
+// TodayCost 今日所有后端的估算费用
+func TodayCost() float64 {
+	var cost float64
+	database.DB.Model(&database.CaptchaStat{}).
+		Where("day = ?", time.Now().Format("2006-01-02")).
+		Select("COALESCE(SUM(cost), 0)").Scan(&cost)
+	return cost
+}
+
+// BudgetExhausted 今日识别费用是否已达到预算（预算为 0 表示不限）
+func BudgetExhausted() bool {
+	return config.CaptchaDailyBudget > 0 && TodayCost() >= config.CaptchaDailyBudget
+}
+
+// SolverStat 某天某个后端的统计及派生指标
+type SolverStat struct {
+	database.CaptchaStat
+	AcceptanceRate float64 // 平台接受率 = 接受 / (接受 + 拒绝)
+	AvgLatencyMs   float64 // 平均耗时
+}
+
+// Stats 返回最近 days 天的统计，以及今日费用和预算
+func Stats(days int) (map[string]interface{}, error) {
+	since := time.Now().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	var rows []database.CaptchaStat
+	if err := database.DB.Where("day >= ?", since).Order("day DESC, solver").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := make([]SolverStat, 0, len(rows))
+	for _, r := range rows {
+		s := SolverStat{CaptchaStat: r}
+		if judged := r.Accepted + r.Rejected; judged > 0 {
+			s.AcceptanceRate = float64(r.Accepted) / float64(judged)
+		}
+		if r.Attempts > 0 {
+			s.AvgLatencyMs = float64(r.LatencyMs) / float64(r.Attempts)
+		}
+		stats = append(stats, s)
+	}
+
+	return map[string]interface{}{
+		"stats":            stats,
+		"today_cost":       TodayCost(),
+		"daily_budget":     config.CaptchaDailyBudget,
+		"budget_exhausted": BudgetExhausted(),
+	}, nil
+}
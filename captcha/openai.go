@@ -0,0 +1,125 @@
+// captcha/openai.go
+package captcha
+
+import (
+	"bytes"
+	"dormcheck/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// visionSolver 调用 OpenAI 兼容的 chat/completions 视觉接口识别验证码
+type visionSolver struct {
+	name     string
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+// newDashScopeSolver 通义千问 DashScope（OpenAI 兼容模式）
+func newDashScopeSolver() *visionSolver {
+	return &visionSolver{
+		name:     "dashscope",
+		endpoint: "https://dashscope.aliyuncs.com/compatible-mode/v1/chat/completions",
+		apiKey:   config.DashScopeAPIKey,
+		model:    config.DashScopeModel,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// newOpenAISolver 任意 OpenAI 兼容的视觉模型接口
+func newOpenAISolver() *visionSolver {
+	return &visionSolver{
+		name:     "openai",
+		endpoint: strings.TrimRight(config.CaptchaOpenAIBaseURL, "/") + "/chat/completions",
+		apiKey:   config.CaptchaOpenAIAPIKey,
+		model:    config.CaptchaOpenAIModel,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *visionSolver) Name() string {
+	return s.name
+}
+
+// Repeatable 视觉模型每次采样结果可能不同，可多次调用参与投票
+func (s *visionSolver) Repeatable() bool {
+	return true
+}
+
+func (s *visionSolver) Solve(base64Image string) (string, error) {
+	if !strings.HasPrefix(base64Image, "data:") {
+		base64Image = "data:image/png;base64," + base64Image
+	}
+
+	reqBody := map[string]interface{}{
+		"model": s.model,
+		"messages": []map[string]interface{}{
+			{
+				"role": "system",
+				"content": []map[string]string{
+					{"type": "text", "text": "你被使用api调用，作用是验证码识别."},
+				},
+			},
+			{
+				"role": "user",
+				"content": []map[string]interface{}{
+					{"type": "image_url", "image_url": map[string]string{"url": base64Image}},
+					{"type": "text", "text": fmt.Sprintf("%d位长度字符类型验证码图像识别，只输出识别结果", config.CaptchaLength)},
+				},
+			},
+		},
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("接口返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return "", err
+	}
+
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("未能识别出验证码")
+	}
+
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
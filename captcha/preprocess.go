@@ -0,0 +1,268 @@
+// captcha/preprocess.go
+package captcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 gif 解码器
+	_ "image/jpeg"
+	_ "image/png"
+	"sort"
+)
+
+// 字符归一化后的尺寸
+const (
+	glyphWidth  = 12
+	glyphHeight = 16
+)
+
+// bitmap 二值化后的图像，true 表示前景（字符笔画）
+type bitmap struct {
+	w, h int
+	px   []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return false
+	}
+	return b.px[y*b.w+x]
+}
+
+// binarize 解码图片、灰度化并用 Otsu 阈值二值化，再去除孤立噪点
+func binarize(imgBytes []byte) (*bitmap, error) {
+	img, _, err := image.Decode(bytes.NewReader(imgBytes))
+	if err != nil {
+		return nil, fmt.Errorf("验证码图片解析失败: %v", err)
+	}
+	return binarizeImage(img)
+}
+
+// binarizeImage 对已解码的图片做二值化与去噪
+func binarizeImage(img image.Image) (*bitmap, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("验证码图片尺寸无效: %dx%d", w, h)
+	}
+	gray := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			v := uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+			gray[y*w+x] = v
+			hist[v]++
+		}
+	}
+
+	threshold := otsu(hist, w*h)
+
+	// 字符笔画占少数：暗类像素不超过一半时为暗字亮底，否则为亮字暗底，反转前景判断。
+	// 不用平均灰度与阈值比较，纯双色图片的阈值为 0 时平均灰度总不小于阈值
+	dark := 0
+	for v := 0; v <= int(threshold); v++ {
+		dark += hist[v]
+	}
+	darkText := dark*2 <= w*h
+
+	bm := &bitmap{w: w, h: h, px: make([]bool, w*h)}
+	for i, v := range gray {
+		if darkText {
+			bm.px[i] = v <= threshold
+		} else {
+			bm.px[i] = v > threshold
+		}
+	}
+
+	return denoise(bm), nil
+}
+
+// otsu 计算使类间方差最大的灰度阈值（暗类为 [0, 阈值]）
+func otsu(hist [256]int, total int) uint8 {
+	var sumAll float64
+	for i, n := range hist {
+		sumAll += float64(i * n)
+	}
+
+	var sumB, best float64
+	var wB int
+	var threshold uint8
+	for t := 0; t < 256; t++ {
+		wB += hist[t]
+		if wB == 0 {
+			continue
+		}
+		wF := total - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		mB := sumB / float64(wB)
+		mF := (sumAll - sumB) / float64(wF)
+		between := float64(wB) * float64(wF) * (mB - mF) * (mB - mF)
+		if between > best {
+			best = between
+			threshold = uint8(t)
+		}
+	}
+	return threshold
+}
+
+// denoise 去除 8 邻域内前景点少于 2 个的孤立噪点
+func denoise(b *bitmap) *bitmap {
+	out := &bitmap{w: b.w, h: b.h, px: make([]bool, len(b.px))}
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if !b.at(x, y) {
+				continue
+			}
+			neighbors := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && b.at(x+dx, y+dy) {
+						neighbors++
+					}
+				}
+			}
+			out.px[y*b.w+x] = neighbors >= 2
+		}
+	}
+	return out
+}
+
+// span 字符在 x 方向上的区间 [start, end)
+type span struct {
+	start, end int
+}
+
+func (s span) width() int {
+	return s.end - s.start
+}
+
+// segment 按列投影把图像切分为 n 个字符区间：粘连的字符从最宽处均分，断开的笔画与最近的区间合并
+func segment(b *bitmap, n int) []span {
+	var spans []span
+	inRun := false
+	for x := 0; x < b.w; x++ {
+		filled := false
+		for y := 0; y < b.h; y++ {
+			if b.at(x, y) {
+				filled = true
+				break
+			}
+		}
+		switch {
+		case filled && !inRun:
+			spans = append(spans, span{start: x})
+			inRun = true
+		case !filled && inRun:
+			spans[len(spans)-1].end = x
+			inRun = false
+		}
+	}
+	if inRun {
+		spans[len(spans)-1].end = b.w
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// 区间过多：反复把最窄的区间并入相邻间隔更小的一侧
+	for len(spans) > n {
+		narrowest := 0
+		for i, s := range spans {
+			if s.width() < spans[narrowest].width() {
+				narrowest = i
+			}
+		}
+		target := narrowest - 1
+		if narrowest == 0 || (narrowest < len(spans)-1 &&
+			spans[narrowest+1].start-spans[narrowest].end < spans[narrowest].start-spans[narrowest-1].end) {
+			target = narrowest + 1
+		}
+		lo, hi := min(narrowest, target), max(narrowest, target)
+		spans[lo] = span{start: spans[lo].start, end: spans[hi].end}
+		spans = append(spans[:hi], spans[hi+1:]...)
+	}
+
+	// 区间过少：把最宽的区间按宽度均分
+	for len(spans) < n {
+		widest := 0
+		for i, s := range spans {
+			if s.width() > spans[widest].width() {
+				widest = i
+			}
+		}
+		s := spans[widest]
+		if s.width() < 2 {
+			break
+		}
+		mid := s.start + s.width()/2
+		spans = append(spans[:widest], append([]span{{s.start, mid}, {mid, s.end}}, spans[widest+1:]...)...)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return spans
+}
+
+// glyphFeatures 裁剪出区间内字符的包围盒并缩放到固定尺寸，返回每个格子的前景占比（0-255）
+func glyphFeatures(b *bitmap, s span) []uint8 {
+	top, bottom := b.h, -1
+	for y := 0; y < b.h; y++ {
+		for x := s.start; x < s.end; x++ {
+			if b.at(x, y) {
+				top = min(top, y)
+				bottom = max(bottom, y)
+			}
+		}
+	}
+
+	features := make([]uint8, glyphWidth*glyphHeight)
+	if bottom < top {
+		return features
+	}
+
+	cropW, cropH := s.width(), bottom-top+1
+	for gy := 0; gy < glyphHeight; gy++ {
+		y0 := top + gy*cropH/glyphHeight
+		y1 := max(top+(gy+1)*cropH/glyphHeight, y0+1)
+		for gx := 0; gx < glyphWidth; gx++ {
+			x0 := s.start + gx*cropW/glyphWidth
+			x1 := max(s.start+(gx+1)*cropW/glyphWidth, x0+1)
+
+			filled, total := 0, 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					total++
+					if b.at(x, y) {
+						filled++
+					}
+				}
+			}
+			features[gy*glyphWidth+gx] = uint8(filled * 255 / total)
+		}
+	}
+	return features
+}
+
+// extractGlyphs 从验证码图片中切分出 n 个字符的特征
+func extractGlyphs(imgBytes []byte, n int) ([][]uint8, error) {
+	bm, err := binarize(imgBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	spans := segment(bm, n)
+	if len(spans) != n {
+		return nil, fmt.Errorf("字符切分失败：期望 %d 个字符，实际 %d 个", n, len(spans))
+	}
+
+	glyphs := make([][]uint8, 0, n)
+	for _, s := range spans {
+		glyphs = append(glyphs, glyphFeatures(bm, s))
+	}
+	return glyphs, nil
+}
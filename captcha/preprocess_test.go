@@ -0,0 +1,131 @@
+// captcha/preprocess_test.go
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// syntheticCaptcha 白底上画若干黑色矩形，每个矩形代表一个字符，rects 为 [x0, x1) 列区间
+func syntheticCaptcha(w, h int, rects [][2]int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for _, r := range rects {
+		for y := 4; y < h-4; y++ {
+			for x := r[0]; x < r[1]; x++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("编码 PNG 失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinarize(t *testing.T) {
+	img := syntheticCaptcha(40, 20, [][2]int{{5, 10}, {20, 25}})
+	bm, err := binarize(encodePNG(t, img))
+	if err != nil {
+		t.Fatalf("二值化失败: %v", err)
+	}
+	if bm.w != 40 || bm.h != 20 {
+		t.Fatalf("尺寸错误: %dx%d", bm.w, bm.h)
+	}
+	if !bm.at(7, 10) || !bm.at(22, 10) {
+		t.Error("字符笔画应为前景")
+	}
+	if bm.at(0, 0) || bm.at(15, 10) || bm.at(7, 1) {
+		t.Error("背景不应为前景")
+	}
+
+	// 亮字暗底时同样把字符识别为前景
+	for i := range img.Pix {
+		img.Pix[i] = 255 - img.Pix[i]
+	}
+	bm, err = binarize(encodePNG(t, img))
+	if err != nil {
+		t.Fatalf("二值化失败: %v", err)
+	}
+	if !bm.at(7, 10) || bm.at(15, 10) {
+		t.Error("亮字暗底时前景判断错误")
+	}
+}
+
+func TestBinarizeRejectsEmptyImage(t *testing.T) {
+	if _, err := binarizeImage(image.NewGray(image.Rect(0, 0, 0, 0))); err == nil {
+		t.Error("期望零尺寸图片返回错误")
+	}
+	if _, err := binarize([]byte("not an image")); err == nil {
+		t.Error("期望无法解码的图片返回错误")
+	}
+}
+
+func TestSegment(t *testing.T) {
+	cases := []struct {
+		name  string
+		rects [][2]int
+		want  []span
+	}{
+		{"分离的字符", [][2]int{{2, 8}, {12, 18}, {22, 28}, {32, 38}}, []span{{2, 8}, {12, 18}, {22, 28}, {32, 38}}},
+		{"粘连的字符从最宽处均分", [][2]int{{2, 8}, {12, 24}, {32, 38}}, []span{{2, 8}, {12, 18}, {18, 24}, {32, 38}}},
+		{"断开的笔画并入相邻字符", [][2]int{{2, 8}, {9, 11}, {20, 26}, {30, 36}, {40, 46}}, []span{{2, 11}, {20, 26}, {30, 36}, {40, 46}}},
+	}
+	for _, tc := range cases {
+		bm, err := binarizeImage(syntheticCaptcha(50, 20, tc.rects))
+		if err != nil {
+			t.Fatalf("%s: 二值化失败: %v", tc.name, err)
+		}
+		got := segment(bm, 4)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: 期望 %v，实际 %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: 期望 %v，实际 %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+
+	bm, _ := binarizeImage(syntheticCaptcha(50, 20, nil))
+	if spans := segment(bm, 4); spans != nil {
+		t.Errorf("空白图片不应切出字符，实际 %v", spans)
+	}
+}
+
+func TestExtractGlyphs(t *testing.T) {
+	img := syntheticCaptcha(50, 20, [][2]int{{2, 8}, {12, 18}, {22, 28}, {32, 38}})
+	glyphs, err := extractGlyphs(encodePNG(t, img), 4)
+	if err != nil {
+		t.Fatalf("切分失败: %v", err)
+	}
+	if len(glyphs) != 4 {
+		t.Fatalf("期望 4 个字符，实际 %d 个", len(glyphs))
+	}
+	for i, g := range glyphs {
+		if len(g) != glyphWidth*glyphHeight {
+			t.Errorf("字符 %d 特征长度 %d", i, len(g))
+		}
+		// 实心矩形裁剪后填满整个格子
+		if g[0] != 255 || g[len(g)-1] != 255 {
+			t.Errorf("字符 %d 特征错误: %v", i, g[:4])
+		}
+	}
+
+	if _, err := extractGlyphs(encodePNG(t, syntheticCaptcha(50, 20, nil)), 4); err == nil {
+		t.Error("空白图片应切分失败")
+	}
+}
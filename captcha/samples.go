@@ -0,0 +1,99 @@
+// captcha/samples.go
+package captcha
+
+import (
+	"archive/zip"
+	"dormcheck/config"
+	"dormcheck/database"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// RecordSample 记录一次验证码识别的结果：更新后端接受率统计，并按配置保存样本；
+// accepted 为平台是否接受该结果，空表示无法判断
+func RecordSample(base64Image, guess, solver, source string, accepted *bool) {
+	recordOutcome(solver, accepted)
+
+	if !config.CaptchaCollect {
+		return
+	}
+
+	sample := database.CaptchaSample{
+		Image:    base64Image,
+		Guess:    guess,
+		Solver:   solver,
+		Source:   source,
+		Accepted: accepted,
+	}
+	if accepted != nil && *accepted {
+		sample.Label = strings.ToLower(guess)
+	}
+
+	if err := database.DB.Create(&sample).Error; err != nil {
+		log.Printf("⚠️ 保存验证码样本失败: %v", err)
+	}
+}
+
+// LabelSample 管理员为样本标注正确文本
+func LabelSample(id uint, label string, adminID int) error {
+	label, ok := Normalize(label)
+	if !ok {
+		return fmt.Errorf("标注文本应为 %d 个有效字符", config.CaptchaLength)
+	}
+
+	result := database.DB.Model(&database.CaptchaSample{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"label":      label,
+		"labeled_by": adminID,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("样本不存在")
+	}
+	return nil
+}
+
+// ExportLabeledSamples 把所有已标注样本打包为 zip，文件名格式 <文本>_<ID>.png，可直接用于 cmd/captcha-train
+func ExportLabeledSamples(w io.Writer) (int, error) {
+	var samples []database.CaptchaSample
+	if err := database.DB.Where("label <> ''").Order("id").Find(&samples).Error; err != nil {
+		return 0, err
+	}
+
+	zw := zip.NewWriter(w)
+	count := 0
+	for _, s := range samples {
+		img, err := decodeImage(s.Image)
+		if err != nil {
+			log.Printf("⚠️ 跳过无法解码的验证码样本 %d: %v", s.ID, err)
+			continue
+		}
+
+		f, err := zw.Create(fmt.Sprintf("%s_%d%s", s.Label, s.ID, imageExt(s.Image)))
+		if err != nil {
+			return count, err
+		}
+		if _, err := f.Write(img); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, zw.Close()
+}
+
+// imageExt 根据 data URI 前缀推断图片扩展名
+func imageExt(base64Image string) string {
+	switch {
+	case strings.HasPrefix(base64Image, "data:image/jpeg"), strings.HasPrefix(base64Image, "data:image/jpg"):
+		return ".jpg"
+	case strings.HasPrefix(base64Image, "data:image/gif"):
+		return ".gif"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,168 @@
+// captcha/solver.go
+package captcha
+
+import (
+	"dormcheck/config"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Solver 验证码识别后端
+type Solver interface {
+	// Name 后端名称，与配置 CAPTCHA_SOLVERS 中的名称一致
+	Name() string
+	// Solve 识别 base64 格式（可带 data URI 前缀）的验证码图像，返回识别结果
+	Solve(base64Image string) (string, error)
+}
+
+// ErrNoSolver 没有可用的验证码识别后端
+var ErrNoSolver = errors.New("未配置可用的验证码识别后端")
+
+// solvers 按配置顺序排列的识别后端，前一个失败时依次尝试下一个
+var solvers []Solver
+
+// InitSolvers 根据配置 CAPTCHA_SOLVERS 构建识别后端链，缺少必要配置的后端会被跳过
+func InitSolvers() {
+	solvers = nil
+	for _, name := range config.CaptchaSolvers {
+		s, err := newSolver(name)
+		if err != nil {
+			log.Printf("⚠️ 验证码识别后端 %s 不可用，已跳过: %v", name, err)
+			continue
+		}
+		solvers = append(solvers, s)
+	}
+
+	if len(solvers) == 0 {
+		log.Println("⚠️ 没有可用的验证码识别后端，学生登录将无法自动完成")
+		return
+	}
+
+	names := make([]string, 0, len(solvers))
+	for _, s := range solvers {
+		names = append(names, s.Name())
+	}
+	log.Printf("🧩 验证码识别后端: %s", strings.Join(names, " -> "))
+}
+
+// newSolver 按名称创建识别后端
+func newSolver(name string) (Solver, error) {
+	switch name {
+	case "dashscope":
+		if config.DashScopeAPIKey == "" {
+			return nil, errors.New("DASHSCOPE_API_KEY 未设置")
+		}
+		return newDashScopeSolver(), nil
+	case "openai":
+		if config.CaptchaOpenAIBaseURL == "" || config.CaptchaOpenAIModel == "" {
+			return nil, errors.New("CAPTCHA_OPENAI_BASE_URL 或 CAPTCHA_OPENAI_MODEL 未设置")
+		}
+		return newOpenAISolver(), nil
+	case "local":
+		args := strings.Fields(config.CaptchaLocalCommand)
+		if len(args) == 0 {
+			return nil, errors.New("CAPTCHA_LOCAL_CMD 未设置")
+		}
+		return &commandSolver{args: args}, nil
+	case "template":
+		model, err := LoadTemplateModel(config.CaptchaModelPath)
+		if err != nil {
+			return nil, fmt.Errorf("离线识别模型加载失败: %v", err)
+		}
+		if model.Length != config.CaptchaLength {
+			return nil, fmt.Errorf("离线识别模型按 %d 位验证码训练，与 CAPTCHA_LENGTH=%d 不符", model.Length, config.CaptchaLength)
+		}
+		return &templateSolver{model: model}, nil
+	case "manual":
+		return &manualSolver{timeout: config.CaptchaManualTimeout}, nil
+	default:
+		return nil, fmt.Errorf("未知的验证码识别后端: %s", name)
+	}
+}
+
+// repeatable 多次调用可能给出不同结果的后端（如视觉大模型），投票时可对同一张图多次采样
+type repeatable interface {
+	Repeatable() bool
+}
+
+// Solve 依次使用配置的识别后端收集 CAPTCHA_VOTES 个有效候选结果并投票，返回识别结果和实际使用的后端名称。
+// 候选结果分歧过大时返回 ErrLowConfidence，调用方应换一张验证码重试
+func Solve(base64Image string) (text string, solver string, err error) {
+	if len(solvers) == 0 {
+		return "", "", ErrNoSolver
+	}
+
+	want := max(config.CaptchaVotes, 1)
+	var candidates []candidate
+	var errs []string
+	asked := 0
+	for _, s := range solvers {
+		if len(candidates) >= want {
+			break
+		}
+
+		n := 1
+		if r, ok := s.(repeatable); ok && r.Repeatable() {
+			n = want - len(candidates)
+		}
+		asked += n
+
+		for _, res := range sample(s, base64Image, n) {
+			if res.err != nil {
+				log.Printf("⚠️ 验证码识别后端 %s 失败: %v", s.Name(), res.err)
+				errs = append(errs, s.Name()+": "+res.err.Error())
+				continue
+			}
+			candidates = append(candidates, candidate{text: res.text, solver: s.Name()})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("所有验证码识别后端均失败（%s）", strings.Join(errs, "; "))
+	}
+
+	winner, confidence := vote(candidates)
+	if len(candidates) > 1 || asked > 1 {
+		log.Printf("🗳️ 验证码投票：%d 个有效候选 / %d 次识别，结果 %s，置信度 %.2f", len(candidates), asked, winner.text, confidence)
+	}
+	if confidence < config.CaptchaMinConfidence {
+		return "", "", fmt.Errorf("%w（%.2f）", ErrLowConfidence, confidence)
+	}
+	return winner.text, winner.solver, nil
+}
+
+// solveResult 一次后端调用的结果
+type solveResult struct {
+	text string
+	err  error
+}
+
+// sample 并发调用后端 n 次，结果经过规范化与格式校验
+func sample(s Solver, base64Image string, n int) []solveResult {
+	results := make([]solveResult, n)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := time.Now()
+			raw, err := s.Solve(base64Image)
+			text := ""
+			if err == nil {
+				var ok bool
+				if text, ok = Normalize(raw); !ok {
+					err = fmt.Errorf("识别结果格式不符: %q", raw)
+				}
+			}
+			recordAttempt(s.Name(), time.Since(start), err)
+			results[i] = solveResult{text: text, err: err}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,93 @@
+// captcha/solver_test.go
+package captcha
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeSolver 返回固定结果的识别后端
+type fakeSolver struct {
+	name string
+	text string
+	err  error
+}
+
+func (f fakeSolver) Name() string                 { return f.name }
+func (f fakeSolver) Solve(string) (string, error) { return f.text, f.err }
+
+func setupSolverTest(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&database.CaptchaStat{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { sqlDB.Close(); solvers = nil })
+
+	config.CaptchaAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	config.CaptchaLength = 4
+	config.CaptchaMinConfidence = 0.5
+}
+
+// 前面的后端都失败时，备用后端（如人工输入）给出的唯一结果不应被判为低置信度
+func TestSolveFallbackIgnoresFailedBackends(t *testing.T) {
+	setupSolverTest(t)
+	config.CaptchaVotes = 3
+	solvers = []Solver{
+		fakeSolver{name: "template", err: errors.New("模型为空")},
+		fakeSolver{name: "dashscope", text: "识别失败"},
+		fakeSolver{name: "manual", text: "AB12"},
+	}
+
+	text, solver, err := Solve("img")
+	if err != nil {
+		t.Fatalf("期望识别成功，实际 %v", err)
+	}
+	if text != "ab12" || solver != "manual" {
+		t.Errorf("期望 ab12/manual，实际 %s/%s", text, solver)
+	}
+}
+
+func TestSolveLowConfidence(t *testing.T) {
+	setupSolverTest(t)
+	config.CaptchaVotes = 2
+	config.CaptchaMinConfidence = 0.6
+	solvers = []Solver{
+		fakeSolver{name: "a", text: "ab12"},
+		fakeSolver{name: "b", text: "cd34"},
+	}
+
+	if _, _, err := Solve("img"); !errors.Is(err, ErrLowConfidence) {
+		t.Errorf("期望 ErrLowConfidence，实际 %v", err)
+	}
+}
+
+// CAPTCHA_LOCAL_CMD 只有空白时应跳过 local 后端，而不是在识别时 panic
+func TestNewSolverRejectsBlankLocalCommand(t *testing.T) {
+	config.CaptchaLocalCommand = "   "
+	if _, err := newSolver("local"); err == nil {
+		t.Error("期望空白命令被拒绝")
+	}
+
+	config.CaptchaLocalCommand = "python3 ocr.py --stdin"
+	s, err := newSolver("local")
+	if err != nil {
+		t.Fatalf("期望创建成功，实际 %v", err)
+	}
+	if args := s.(*commandSolver).args; len(args) != 3 || args[0] != "python3" {
+		t.Errorf("命令解析错误: %q", args)
+	}
+}
@@ -0,0 +1,212 @@
+// captcha/template.go
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// templateModelVersion 模型文件格式版本（2 起记录验证码字符数）
+const templateModelVersion = 2
+
+// glyphTemplate 一个已标注的字符样本
+type glyphTemplate struct {
+	Label    string  `json:"label"`
+	Features []uint8 `json:"features"`
+}
+
+// TemplateModel 离线识别模型：保存所有训练字符样本，识别时按 k 近邻投票
+type TemplateModel struct {
+	Version int             `json:"version"`
+	Width   int             `json:"width"`
+	Height  int             `json:"height"`
+	Length  int             `json:"length"` // 每张验证码的字符个数
+	Glyphs  []glyphTemplate `json:"glyphs"`
+}
+
+// LabeledSample 带标注的验证码样本
+type LabeledSample struct {
+	Label string // 验证码文本
+	Image []byte // 图片原始字节
+}
+
+// TrainTemplateModel 用已标注样本训练模型，length 为每张验证码的字符个数；
+// 返回模型和因标注长度不符或切分失败而跳过的样本数
+func TrainTemplateModel(samples []LabeledSample, length int) (*TemplateModel, int) {
+	model := &TemplateModel{
+		Version: templateModelVersion,
+		Width:   glyphWidth,
+		Height:  glyphHeight,
+		Length:  length,
+	}
+
+	skipped := 0
+	for _, s := range samples {
+		label := []rune(strings.ToLower(s.Label))
+		if len(label) != length {
+			skipped++
+			continue
+		}
+
+		glyphs, err := extractGlyphs(s.Image, length)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		for i, g := range glyphs {
+			model.Glyphs = append(model.Glyphs, glyphTemplate{Label: string(label[i]), Features: g})
+		}
+	}
+
+	return model, skipped
+}
+
+// Recognize 识别一张验证码图片
+func (m *TemplateModel) Recognize(imgBytes []byte) (string, error) {
+	if len(m.Glyphs) == 0 {
+		return "", errors.New("离线识别模型为空")
+	}
+
+	glyphs, err := extractGlyphs(imgBytes, m.Length)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, g := range glyphs {
+		sb.WriteString(m.classify(g))
+	}
+	return sb.String(), nil
+}
+
+// classify k 近邻（k=3）投票，票数相同时取距离最近的
+func (m *TemplateModel) classify(features []uint8) string {
+	const k = 3
+
+	type neighbor struct {
+		label string
+		dist  int
+	}
+	nearest := make([]neighbor, 0, k+1)
+	for _, g := range m.Glyphs {
+		d := distance(features, g.Features)
+		if len(nearest) == k && d >= nearest[k-1].dist {
+			continue
+		}
+		nearest = append(nearest, neighbor{g.Label, d})
+		sort.Slice(nearest, func(i, j int) bool { return nearest[i].dist < nearest[j].dist })
+		if len(nearest) > k {
+			nearest = nearest[:k]
+		}
+	}
+
+	votes := make(map[string]int)
+	best := nearest[0].label
+	for _, n := range nearest {
+		votes[n.label]++
+		if votes[n.label] > votes[best] {
+			best = n.label
+		}
+	}
+	return best
+}
+
+// distance 两个特征向量的平方欧氏距离
+func distance(a, b []uint8) int {
+	d := 0
+	for i := range a {
+		diff := int(a[i]) - int(b[i])
+		d += diff * diff
+	}
+	return d
+}
+
+// EvalResult 模型在样本集上的准确率
+type EvalResult struct {
+	Total        int     // 样本数
+	Correct      int     // 整串识别正确数
+	Accuracy     float64 // 整串准确率
+	CharTotal    int     // 字符数
+	CharCorrect  int     // 字符识别正确数
+	CharAccuracy float64 // 单字符准确率
+}
+
+// Evaluate 在留出集上评估模型准确率（切分失败的样本计为识别错误）
+func (m *TemplateModel) Evaluate(samples []LabeledSample) EvalResult {
+	var r EvalResult
+	for _, s := range samples {
+		label := []rune(strings.ToLower(s.Label))
+		r.Total++
+		r.CharTotal += len(label)
+
+		text, err := m.Recognize(s.Image)
+		if err != nil {
+			continue
+		}
+		if text == string(label) {
+			r.Correct++
+		}
+		guess := []rune(text)
+		for i := 0; i < len(label) && i < len(guess); i++ {
+			if guess[i] == label[i] {
+				r.CharCorrect++
+			}
+		}
+	}
+
+	if r.Total > 0 {
+		r.Accuracy = float64(r.Correct) / float64(r.Total)
+	}
+	if r.CharTotal > 0 {
+		r.CharAccuracy = float64(r.CharCorrect) / float64(r.CharTotal)
+	}
+	return r
+}
+
+// Save 保存模型到文件
+func (m *TemplateModel) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadTemplateModel 从文件加载模型
+func LoadTemplateModel(path string) (*TemplateModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m TemplateModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("模型文件解析失败: %v", err)
+	}
+	if m.Version != templateModelVersion || m.Width != glyphWidth || m.Height != glyphHeight || m.Length <= 0 {
+		return nil, fmt.Errorf("模型文件版本或尺寸不匹配，请重新训练")
+	}
+	return &m, nil
+}
+
+// templateSolver 纯 Go 离线识别后端
+type templateSolver struct {
+	model *TemplateModel
+}
+
+func (s *templateSolver) Name() string {
+	return "template"
+}
+
+func (s *templateSolver) Solve(base64Image string) (string, error) {
+	img, err := decodeImage(base64Image)
+	if err != nil {
+		return "", err
+	}
+	return s.model.Recognize(img)
+}
@@ -0,0 +1,64 @@
+// captcha/vote.go
+package captcha
+
+import (
+	"dormcheck/config"
+	"errors"
+	"strings"
+)
+
+// ErrLowConfidence 多个候选结果分歧过大，提交大概率失败，应换一张验证码
+var ErrLowConfidence = errors.New("验证码识别置信度过低")
+
+// Normalize 把识别结果规范化为小写并丢弃字母表外的字符（空格、标点、模型附带的说明文字等），
+// 长度不等于 CAPTCHA_LENGTH 时返回 false
+func Normalize(text string) (string, bool) {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		if strings.ContainsRune(config.CaptchaAlphabet, r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	normalized := sb.String()
+	return normalized, len([]rune(normalized)) == config.CaptchaLength
+}
+
+// candidate 一个通过格式校验的候选结果
+type candidate struct {
+	text   string
+	solver string
+}
+
+// vote 逐位投票得出结果。置信度取各位上最高票数占有效候选数的最小值；
+// 失败或格式不符的识别不计入，否则备用后端（如人工输入）给出的唯一结果会被判为低置信度
+func vote(candidates []candidate) (candidate, float64) {
+	total := len(candidates)
+	length := config.CaptchaLength
+	confidence := 1.0
+	result := make([]rune, length)
+
+	for pos := 0; pos < length; pos++ {
+		counts := make(map[rune]int)
+		var best rune
+		for _, c := range candidates {
+			r := []rune(c.text)[pos]
+			counts[r]++
+			if counts[r] > counts[best] {
+				best = r
+			}
+		}
+		result[pos] = best
+		confidence = min(confidence, float64(counts[best])/float64(total))
+	}
+
+	// 结果记到第一个给出相同答案的后端名下；逐位拼出的结果无人完全给出时记到第一个候选的后端
+	winner := candidate{text: string(result), solver: candidates[0].solver}
+	for _, c := range candidates {
+		if c.text == winner.text {
+			winner.solver = c.solver
+			break
+		}
+	}
+	return winner, confidence
+}
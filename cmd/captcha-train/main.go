@@ -0,0 +1,62 @@
+// cmd/captcha-train/main.go
+// 训练并评估内置离线验证码识别模型：
+//
+//	go run ./cmd/captcha-train -data captcha_samples -out captcha_model.json -holdout 0.2 -length 4
+//	go run ./cmd/captcha-train -data captcha_heldout -eval captcha_model.json
+package main
+
+import (
+	"dormcheck/captcha"
+	"flag"
+	"fmt"
+	"log"
+)
+
+func main() {
+	dataDir := flag.String("data", "captcha_samples", "已标注验证码图片目录，文件名格式为 <验证码文本>_<任意后缀>.png")
+	out := flag.String("out", "captcha_model.json", "模型输出路径")
+	length := flag.Int("length", 4, "每张验证码的字符个数，需与服务端 CAPTCHA_LENGTH 一致")
+	holdout := flag.Float64("holdout", 0.2, "留出集比例，用于评估准确率")
+	seed := flag.Int64("seed", 1, "划分训练集/留出集的随机种子")
+	eval := flag.String("eval", "", "仅评估：指定已有模型文件，在 -data 的全部样本上计算准确率")
+	flag.Parse()
+
+	if *length <= 0 {
+		log.Fatalf("❌ -length 必须为正数")
+	}
+
+	samples, err := captcha.LoadSamplesDir(*dataDir)
+	if err != nil {
+		log.Fatalf("❌ 读取样本失败: %v", err)
+	}
+	if len(samples) == 0 {
+		log.Fatalf("❌ 目录 %s 中没有样本", *dataDir)
+	}
+
+	if *eval != "" {
+		model, err := captcha.LoadTemplateModel(*eval)
+		if err != nil {
+			log.Fatalf("❌ 加载模型失败: %v", err)
+		}
+		printResult("评估集", model.Evaluate(samples))
+		return
+	}
+
+	train, test := captcha.SplitSamples(samples, *holdout, *seed)
+	model, skipped := captcha.TrainTemplateModel(train, *length)
+	fmt.Printf("训练样本 %d 个（切分失败跳过 %d 个），字符模板 %d 个\n", len(train), skipped, len(model.Glyphs))
+
+	if len(test) > 0 {
+		printResult("留出集", model.Evaluate(test))
+	}
+
+	if err := model.Save(*out); err != nil {
+		log.Fatalf("❌ 保存模型失败: %v", err)
+	}
+	fmt.Printf("✅ 模型已保存到 %s\n", *out)
+}
+
+func printResult(name string, r captcha.EvalResult) {
+	fmt.Printf("%s：%d 个样本，整串准确率 %.2f%%（%d/%d），单字符准确率 %.2f%%（%d/%d）\n",
+		name, r.Total, r.Accuracy*100, r.Correct, r.Total, r.CharAccuracy*100, r.CharCorrect, r.CharTotal)
+}
@@ -0,0 +1,36 @@
+// cmd/rekey/main.go
+// 轮换学生密码与 cookies 的加密主密钥，并把旧的 enc:v1 格式（只绑定列名）升级为绑定学号的 enc:v2：
+//
+//	DATA_ENCRYPTION_KEY=<新密钥> DATA_ENCRYPTION_OLD_KEYS=<旧密钥> go run ./cmd/rekey
+//
+// 完成后即可从 DATA_ENCRYPTION_OLD_KEYS 中移除旧密钥
+package main
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"fmt"
+	"log"
+)
+
+func main() {
+	config.InitConfig()
+	database.InitDB() // 启动迁移会把明文和旧密钥加密的数据统一轮换到当前密钥
+
+	// 逐个读取，确认所有记录都能用当前配置解密
+	var students []database.Student
+	if err := database.DB.Find(&students).Error; err != nil {
+		log.Fatalf("❌ 校验解密失败: %v", err)
+	}
+
+	var legacy int64
+	if err := database.DB.Table("students").
+		Where("password LIKE ? OR cookies LIKE ?", "enc:v1:%", "enc:v1:%").Count(&legacy).Error; err != nil {
+		log.Fatalf("❌ 检查旧格式数据失败: %v", err)
+	}
+	if legacy > 0 {
+		log.Fatalf("❌ 仍有 %d 名学生的数据为旧格式 enc:v1", legacy)
+	}
+
+	fmt.Printf("✅ 轮换完成：共 %d 名学生的数据均可用当前密钥解密\n", len(students))
+}
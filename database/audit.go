@@ -0,0 +1,104 @@
+// database/audit.go
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditAppendOnly 审计日志只允许追加
+var ErrAuditAppendOnly = errors.New("审计日志只允许追加，不能修改或删除")
+
+// AuditEvent 账号与凭据相关的安全审计事件，只追加不修改
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	ActorID    int       `gorm:"index"` // 操作者用户ID，未登录（如登录失败、重置密码）时为 0
+	IP         string    `gorm:"index"`
+	UserAgent  string
+	Action     string `gorm:"index;not null"`         // 见 Audit* 常量
+	TargetType string `gorm:"index:idx_audit_target"` // 操作对象类型：user / student / session / token 等
+	TargetID   string `gorm:"index:idx_audit_target"`
+	Result     string `gorm:"index;not null"` // 见 AuditResult* 常量
+	Detail     string // 失败原因或补充说明
+}
+
+// 审计结果
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// 审计对象类型
+const (
+	AuditTargetUser    = "user"
+	AuditTargetStudent = "student"
+	AuditTargetSession = "session"
+	AuditTargetToken   = "token"
+	AuditTargetEmail   = "email"
+
+	AuditTargetBindRequest = "bind_request"
+	AuditTargetCaptcha     = "captcha_sample"
+)
+
+// 审计操作
+const (
+	AuditRegister           = "register"
+	AuditLogin              = "login"
+	AuditLogin2FA           = "login_2fa"
+	AuditReverify           = "reverify"
+	AuditLogoutAll          = "logout_all"
+	AuditSessionRevoke      = "session_revoke"
+	AuditPasswordChange     = "password_change"
+	AuditPasswordReset      = "password_reset"
+	AuditEmailChange        = "email_change"
+	AuditTwoFactorEnable    = "2fa_enable"
+	AuditTwoFactorDisable   = "2fa_disable"
+	AuditBackupCodesReset   = "2fa_backup_codes"
+	AuditTokenCreate        = "token_create"
+	AuditTokenRevoke        = "token_revoke"
+	AuditSponsorActivate    = "sponsor_activate"
+	AuditStudentBind        = "student_bind"
+	AuditStudentBindRequest = "student_bind_request"
+	AuditStudentBindLocked  = "student_bind_locked"
+	AuditStudentUnbind      = "student_unbind"
+	AuditStudentCredential  = "student_credentials"
+	AuditBindRequestApprove = "bind_request_approve"
+	AuditBindRequestReject  = "bind_request_reject"
+	AuditAdminUnlockUser    = "admin_unlock_user"
+	AuditAdminSetRole       = "admin_set_role"
+	AuditAdminCaptchaLabel  = "admin_captcha_label"
+)
+
+func (AuditEvent) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+func (AuditEvent) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// createAuditTriggers 在数据库层禁止修改和删除审计事件，防止绕过 ORM 篡改
+func createAuditTriggers(db *gorm.DB) error {
+	for _, op := range []string{"UPDATE", "DELETE"} {
+		if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS audit_events_no_` + op + `
+			BEFORE ` + op + ` ON audit_events
+			BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END`).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordAuditEvent 追加一条审计事件，失败只打日志不影响业务
+func RecordAuditEvent(e AuditEvent) {
+	if e.Result == "" {
+		e.Result = AuditResultSuccess
+	}
+	if err := DB.Create(&e).Error; err != nil {
+		log.Printf("⚠️ 记录审计事件失败 %s: %v", e.Action, err)
+	}
+}
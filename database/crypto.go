@@ -0,0 +1,318 @@
+// database/crypto.go
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"dormcheck/config"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// 加密字段格式：enc:v2:<主密钥ID>:<被主密钥加密的数据密钥>:<被数据密钥加密的内容>
+// 每个字段值使用独立的随机数据密钥（信封加密），轮换主密钥时只需重新加密数据密钥。
+// v2 的附加认证数据为 "列名:行主键"，密文挪到其他列或其他学生的行都无法解密；
+// v1 只绑定列名，仅用于读取旧数据，启动迁移时统一升级为 v2
+const (
+	encryptedPrefix = "enc:v2:"
+	legacyPrefix    = "enc:v1:"
+)
+
+// fieldAAD 字段的附加认证数据
+func fieldAAD(column, rowID string) []byte {
+	return []byte(column + ":" + rowID)
+}
+
+func isEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, encryptedPrefix) || strings.HasPrefix(stored, legacyPrefix)
+}
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// keyID 主密钥的短标识，用于在轮换期间找到加密时使用的密钥
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// findKey 按标识查找当前或旧的主密钥
+func findKey(id string) ([]byte, error) {
+	if keyID(config.DataEncryptionKey) == id {
+		return config.DataEncryptionKey, nil
+	}
+	for _, key := range config.DataEncryptionOldKeys {
+		if keyID(key) == id {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("找不到加密密钥 %s，请在 DATA_ENCRYPTION_OLD_KEYS 中配置旧密钥", id)
+}
+
+// seal AES-GCM 加密，返回 nonce + 密文
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open 解密 seal 的输出
+func open(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("密文长度错误")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+// encryptedField 解析后的加密字段
+type encryptedField struct {
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func parseEncrypted(stored string) (*encryptedField, error) {
+	parts := strings.Split(stored[len(encryptedPrefix):], ":")
+	if len(parts) != 3 {
+		return nil, errors.New("加密字段格式错误")
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("加密字段格式错误: %v", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("加密字段格式错误: %v", err)
+	}
+	return &encryptedField{keyID: parts[0], wrappedKey: wrappedKey, ciphertext: ciphertext}, nil
+}
+
+func (f *encryptedField) String() string {
+	return encryptedPrefix + f.keyID + ":" +
+		base64.RawStdEncoding.EncodeToString(f.wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(f.ciphertext)
+}
+
+// EncryptField 使用当前主密钥加密字段值；列名和行主键（学号）作为附加认证数据，
+// 防止密文被挪到其他列或其他行使用。空值不加密
+func EncryptField(column, rowID, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	aad := fieldAAD(column, rowID)
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(config.DataEncryptionKey, dataKey, aad)
+	if err != nil {
+		return "", err
+	}
+
+	f := encryptedField{keyID: keyID(config.DataEncryptionKey), wrappedKey: wrappedKey, ciphertext: ciphertext}
+	return f.String(), nil
+}
+
+// DecryptField 解密字段值；未加密的历史数据原样返回
+func DecryptField(column, rowID, stored string) (string, error) {
+	if !isEncrypted(stored) {
+		return stored, nil
+	}
+	aad := fieldAAD(column, rowID)
+	if strings.HasPrefix(stored, legacyPrefix) {
+		aad = []byte(column)
+	}
+
+	f, err := parseEncrypted(stored)
+	if err != nil {
+		return "", err
+	}
+	masterKey, err := findKey(f.keyID)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(masterKey, f.wrappedKey, aad)
+	if err != nil {
+		return "", fmt.Errorf("数据密钥解密失败: %v", err)
+	}
+	plaintext, err := open(dataKey, f.ciphertext, aad)
+	if err != nil {
+		return "", fmt.Errorf("字段解密失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// rewrapField 把字段转换为当前格式和主密钥加密：明文和 v1 格式重新加密，旧密钥加密的只重新加密数据密钥。
+// 已是当前格式和密钥的返回 false
+func rewrapField(column, rowID, stored string) (string, bool, error) {
+	if stored == "" {
+		return stored, false, nil
+	}
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		plaintext, err := DecryptField(column, rowID, stored)
+		if err != nil {
+			return "", false, err
+		}
+		encrypted, err := EncryptField(column, rowID, plaintext)
+		return encrypted, true, err
+	}
+	aad := fieldAAD(column, rowID)
+
+	f, err := parseEncrypted(stored)
+	if err != nil {
+		return "", false, err
+	}
+	currentID := keyID(config.DataEncryptionKey)
+	if f.keyID == currentID {
+		return stored, false, nil
+	}
+
+	oldKey, err := findKey(f.keyID)
+	if err != nil {
+		return "", false, err
+	}
+	dataKey, err := open(oldKey, f.wrappedKey, aad)
+	if err != nil {
+		return "", false, fmt.Errorf("数据密钥解密失败: %v", err)
+	}
+	if f.wrappedKey, err = seal(config.DataEncryptionKey, dataKey, aad); err != nil {
+		return "", false, err
+	}
+	f.keyID = currentID
+	return f.String(), true, nil
+}
+
+// EncryptedSerializer GORM 序列化器：写库时加密、读库时解密，字段标签 serializer:encrypted。
+// 行主键参与附加认证数据，读取加密列时必须同时查询主键（GORM 按列顺序赋值，主键需排在前面）
+type EncryptedSerializer struct{}
+
+// rowID 取模型的主键值
+func rowID(ctx context.Context, field *schema.Field, dst reflect.Value) (string, error) {
+	pk := field.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return "", fmt.Errorf("加密字段 %s 所在的表没有主键", field.DBName)
+	}
+	v, zero := pk.ValueOf(ctx, dst)
+	if zero {
+		return "", fmt.Errorf("加密字段 %s 缺少主键 %s", field.DBName, pk.DBName)
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("加密字段 %s 类型不支持: %T", field.DBName, dbValue)
+	}
+	if stored == "" {
+		return field.Set(ctx, dst, "")
+	}
+
+	id, err := rowID(ctx, field, dst)
+	if err != nil {
+		return err
+	}
+	plaintext, err := DecryptField(field.DBName, id, stored)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field.DBName, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("加密字段 %s 只支持 string 类型", field.DBName)
+	}
+	if plaintext == "" {
+		return "", nil
+	}
+
+	id, err := rowID(ctx, field, dst)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptField(field.DBName, id, plaintext)
+}
+
+// EncryptStudentSecrets 把 students 表中的明文密码/cookies 加密，把 v1 格式升级为 v2，
+// 并把旧主密钥加密的数据轮换到当前主密钥，返回更新的行数
+func EncryptStudentSecrets() (int, error) {
+	var rows []struct {
+		StuID    string
+		Password string
+		Cookies  string
+	}
+	// 直接读取原始列值，绕过序列化器
+	if err := DB.Table("students").Select("stu_id", "password", "cookies").Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, row := range rows {
+		password, pwdChanged, err := rewrapField("password", row.StuID, row.Password)
+		if err != nil {
+			return updated, fmt.Errorf("学号 %s 密码: %w", row.StuID, err)
+		}
+		cookies, cookiesChanged, err := rewrapField("cookies", row.StuID, row.Cookies)
+		if err != nil {
+			return updated, fmt.Errorf("学号 %s cookies: %w", row.StuID, err)
+		}
+		if !pwdChanged && !cookiesChanged {
+			continue
+		}
+
+		if err := DB.Table("students").Where("stu_id = ?", row.StuID).UpdateColumns(map[string]interface{}{
+			"password": password,
+			"cookies":  cookies,
+		}).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		log.Printf("🔐 已加密/轮换 %d 名学生的密码与 cookies", updated)
+	}
+	return updated, nil
+}
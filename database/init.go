@@ -22,18 +22,59 @@ func InitDB() {
 		log.Fatal("无法连接数据库:", err)
 	}
 
+	// 验证码改为只保存哈希：旧表含明文列，验证码本身是临时数据，直接重建（用户重新获取即可）
+	if DB.Migrator().HasColumn(&EmailVerificationCode{}, "code") {
+		if err := DB.Migrator().DropTable(&EmailVerificationCode{}); err != nil {
+			log.Fatal("删除旧验证码表失败:", err)
+		}
+	}
+
+	// 旧的绑定申请都是密码校验通过后才创建的，迁移后标记为密码一致
+	backfillBindMatched := DB.Migrator().HasTable(&StudentBindRequest{}) &&
+		!DB.Migrator().HasColumn(&StudentBindRequest{}, "password_matched")
+
 	// 自动迁移表结构
 	err = DB.AutoMigrate(
 		&User{},
+		&UserSession{},
+		&UserBackupCode{},
+		&PersonalAccessToken{},
 		&UserStudent{},
 		&Student{},
+		&StudentBindRequest{},
+		&StudentAuditLog{},
 		&Task{},
 		&EmailVerificationCode{},
 		&SponsorActivationCode{},
+		&CaptchaSample{},
+		&CaptchaStat{},
+		&AuditEvent{},
 	)
 	if err != nil {
 		log.Fatal("数据库迁移失败:", err)
 	}
 
+	if err := createAuditTriggers(DB); err != nil {
+		log.Fatal("创建审计日志触发器失败:", err)
+	}
+
+	if backfillBindMatched {
+		if err := DB.Exec("UPDATE student_bind_requests SET password_matched = ?", true).Error; err != nil {
+			log.Fatal("回填绑定申请失败:", err)
+		}
+	}
+
+	// 历史学号没有所有者：取最早绑定的用户作为所有者
+	if err := DB.Exec(`UPDATE students SET owner_user_id = (
+		SELECT user_id FROM user_students WHERE user_students.stu_id = students.stu_id ORDER BY id LIMIT 1
+	) WHERE owner_user_id IS NULL`).Error; err != nil {
+		log.Fatal("学号所有者迁移失败:", err)
+	}
+
+	// 加密历史明文数据，并把旧密钥加密的数据轮换到当前密钥
+	if _, err := EncryptStudentSecrets(); err != nil {
+		log.Fatal("学生敏感字段加密迁移失败:", err)
+	}
+
 	log.Println("✅ 数据库连接成功，迁移完成")
 }
@@ -10,15 +10,64 @@ type User struct { // 平台用户信息
 	EmailVerified bool   `gorm:"default:false"`   // 邮箱验证状态
 	Password      string `gorm:"not null"`        // 密码哈希
 	TokenVersion  int
-	Role          int
+	Role          Role          // 见 roles.go
 	UserStudents  []UserStudent `gorm:"foreignKey:UserID"`
+
+	FailedLoginCount  int        // 连续登录失败次数
+	LastFailedLoginAt *time.Time // 最近一次登录失败时间
+	LockedUntil       *time.Time // 账号锁定截止时间，空表示未锁定
+
+	TOTPSecret   string `gorm:"serializer:encrypted"` // 两步验证密钥（加密存储），设置后需验证一次才启用
+	TOTPEnabled  bool   `gorm:"default:false"`        // 是否已启用两步验证
+	TOTPLastStep int64  `gorm:"default:0"`            // 最近一次使用的验证码时间步，防止同一验证码重复使用
+}
+
+// UserBackupCode 两步验证的备用恢复码，每个只能使用一次
+type UserBackupCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int    `gorm:"index;not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// UserSession 用户在某台设备上的登录会话，刷新令牌只保存哈希
+type UserSession struct {
+	ID                uint       `gorm:"primaryKey"`
+	UserID            int        `gorm:"index;not null"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null"` // 当前刷新令牌的 SHA-256
+	PreviousTokenHash string     `gorm:"index"`                // 上一个（已轮换掉的）刷新令牌，被再次使用说明令牌泄露
+	DeviceName        string     // 设备名称，由客户端提供
+	IP                string     // 最近一次使用的 IP
+	UserAgent         string     // 最近一次使用的 User-Agent
+	CreatedAt         time.Time  // 登录时间
+	LastSeenAt        time.Time  // 最近活跃时间
+	ExpiresAt         time.Time  `gorm:"index"` // 刷新令牌过期时间
+	RevokedAt         *time.Time // 注销时间，非空表示会话已失效
+	ReverifiedAt      *time.Time // 最近一次重新验证身份的时间，敏感操作需要在有效期内
+}
+
+// PersonalAccessToken 用户为脚本和第三方集成创建的个人访问令牌，只保存哈希，权限受 Scopes 限制
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     int        `gorm:"index;not null"`
+	Name       string     `gorm:"not null"`             // 令牌名称，由用户填写
+	Prefix     string     `gorm:"not null"`             // 令牌开头几位，便于用户辨认
+	TokenHash  string     `gorm:"uniqueIndex;not null"` // 令牌的 SHA-256
+	Scopes     string     `gorm:"not null"`             // 权限范围，英文逗号分隔，见 TokenScope
+	ExpiresAt  time.Time  `gorm:"index"`
+	LastUsedAt *time.Time // 最近使用时间
+	LastUsedIP string     // 最近使用的 IP
+	CreatedAt  time.Time
+	RevokedAt  *time.Time // 吊销时间，非空表示已失效
 }
 
 type EmailVerificationCode struct {
 	ID        int       `gorm:"primaryKey"`
 	Email     string    `gorm:"index;not null"`
-	Code      string    `gorm:"not null"` // 验证码，6位或其他长度
-	Purpose   string    `gorm:"not null"` // 用途：register/login/reset
+	CodeHash  string    `gorm:"not null"` // 验证码的 HMAC-SHA256，不保存明文
+	Purpose   string    `gorm:"not null"` // 用途：register/change_email/reset
+	Attempts  int       // 输入错误次数，达到上限后作废
 	ExpiresAt time.Time `gorm:"not null"` // 过期时间
 	CreatedAt time.Time
 }
@@ -32,12 +81,79 @@ type UserStudent struct { // 用户绑定学生
 
 type Student struct { // 存储学生信息（学号、密码、cookies等）
 	StuID     string    `gorm:"primaryKey"`
-	Password  string    `gorm:"not null"`
-	Cookies   string    `gorm:"type:text"` // 存储序列化后的 cookies
+	School    string    `gorm:"default:swmu"`                   // 所属学校（平台适配器标识）
+	Password  string    `gorm:"not null;serializer:encrypted"`  // 加密存储，见 crypto.go
+	Cookies   string    `gorm:"type:text;serializer:encrypted"` // 存储序列化后的 cookies（加密）
 	LastLogin time.Time `gorm:"not null"`
 	Name      string    `gorm:""`
+
+	OwnerUserID *int `gorm:"index"` // 所有者：只有所有者能更新密码和 cookies，其他用户绑定需其批准；空表示暂无所有者
+
+	SessionExpiresAt *time.Time `gorm:"index"` // 登录态预计失效时间，空表示未知
+
+	SessionStatus    string     `gorm:"default:unknown"` // 最近一次登录态探测结果，见 SessionStatus* 常量
+	SessionCheckedAt *time.Time // 最近一次探测时间
+	LastRefreshAt    *time.Time // 最近一次刷新 cookies 的时间
+	LastRefreshOK    bool       // 最近一次刷新是否成功
+	LastRefreshError string     // 最近一次刷新失败的原因
+
+	CredentialFailures   int        `gorm:"default:0"`     // 自动登录时密码连续被平台拒绝的次数
+	CredentialsInvalid   bool       `gorm:"default:false"` // 密码已判定失效，任务已暂停，等待所有者更新密码
+	CredentialsInvalidAt *time.Time // 判定失效的时间
 }
 
+// 登录态探测结果
+const (
+	SessionStatusUnknown = "unknown" // 尚未探测
+	SessionStatusOK      = "ok"      // 登录态有效
+	SessionStatusExpired = "expired" // 登录态已失效
+	SessionStatusError   = "error"   // 探测请求失败（平台异常等），无法判断
+)
+
+// StudentBindRequest 其他用户申请绑定已有所有者的学号，需所有者批准
+type StudentBindRequest struct {
+	ID          uint   `gorm:"primaryKey"`
+	StuID       string `gorm:"index;not null"`
+	RequesterID int    `gorm:"index;not null"` // 申请绑定的用户
+	OwnerUserID int    `gorm:"index;not null"` // 申请时的所有者
+	Status      string `gorm:"index;not null"` // 见 BindRequest* 常量
+	// 申请时的密码是否与所有者保存的一致；不一致的申请不展示给所有者，也不能被批准
+	PasswordMatched bool
+	CreatedAt       time.Time
+	DecidedAt       *time.Time
+}
+
+// 绑定申请状态
+const (
+	BindRequestPending  = "pending"
+	BindRequestApproved = "approved"
+	BindRequestRejected = "rejected"
+)
+
+// StudentAuditLog 学号绑定、授权与凭据变更记录
+type StudentAuditLog struct {
+	ID        uint   `gorm:"primaryKey"`
+	StuID     string `gorm:"index;not null"`
+	UserID    int    `gorm:"index"` // 操作者，系统操作为 0
+	Action    string `gorm:"index;not null"`
+	Detail    string
+	CreatedAt time.Time
+}
+
+// 学号审计操作
+const (
+	StudentAuditBind         = "bind"          // 绑定（所有者，或首次绑定成为所有者）
+	StudentAuditBindRequest  = "bind_request"  // 申请绑定他人所有的学号
+	StudentAuditBindLocked   = "bind_locked"   // 申请次数过多被临时锁定
+	StudentAuditBindApprove  = "bind_approve"  // 所有者批准绑定申请
+	StudentAuditBindReject   = "bind_reject"   // 所有者拒绝绑定申请
+	StudentAuditUnbind       = "unbind"        // 解绑
+	StudentAuditOwnerChanged = "owner_changed" // 所有者变更
+	StudentAuditCredentials  = "credentials"   // 所有者更新登录密码
+
+	StudentAuditCredentialsInvalid = "credentials_invalid" // 密码连续被拒，判定失效（系统操作）
+)
+
 type Task struct {
 	ID           uint   `gorm:"primaryKey"`
 	UserID       int    `gorm:"index;uniqueIndex:idx_user_activity_time"`
@@ -52,14 +168,53 @@ type Task struct {
 
 	NotifyEmail string `gorm:"size:255"` // ✅ 新增：用于通知的邮箱，可为空
 
-	Enabled    bool
-	ExecStatus string // "pending" | "success" | "failed"
-	RetryCount int
-	MaxRetry   int
-	LastError  string
-	ExecutedAt time.Time
+	Enabled      bool
+	PausedReason string // 非空表示任务被系统暂停（如学号密码失效），恢复后清空
+	ExecStatus   string // "pending" | "success" | "failed"
+	RetryCount   int
+	MaxRetry     int
+	LastError    string
+	ExecutedAt   time.Time
 }
 
+// 任务被系统暂停的原因
+const (
+	TaskPausedCredentials = "学校平台密码已失效，请更新密码后自动恢复"
+)
+
+// CaptchaSample 登录时识别过的验证码样本，用于评估和训练识别模型
+type CaptchaSample struct {
+	ID        uint   `gorm:"primaryKey"`
+	Image     string `gorm:"type:text;not null"` // base64 图像（带 data URI 前缀）
+	Guess     string // 识别结果
+	Solver    string `gorm:"index"` // 识别后端
+	Source    string `gorm:"index"` // 来源，见 CaptchaSource* 常量
+	Accepted  *bool  // 平台是否接受该识别结果，空表示无法判断
+	Label     string `gorm:"index"` // 正确文本：平台接受时自动填入，否则由管理员标注
+	LabeledBy *int   // 标注的管理员 ID，自动填入时为空
+	CreatedAt time.Time
+}
+
+// CaptchaStat 每个识别后端每天的调用统计
+type CaptchaStat struct {
+	ID        uint    `gorm:"primaryKey"`
+	Day       string  `gorm:"uniqueIndex:idx_day_solver;not null"` // 格式：YYYY-MM-DD
+	Solver    string  `gorm:"uniqueIndex:idx_day_solver;not null"`
+	Attempts  int     // 调用次数
+	Successes int     // 返回了识别结果的次数
+	Errors    int     // 调用失败次数
+	Accepted  int     // 识别结果被平台接受的次数
+	Rejected  int     // 识别结果被平台判定错误的次数
+	LatencyMs int64   // 累计耗时（毫秒）
+	Cost      float64 // 累计估算费用（元）
+}
+
+// 验证码样本来源
+const (
+	CaptchaSourceBind    = "bind"    // 绑定学号
+	CaptchaSourceRefresh = "refresh" // 定时刷新 cookies
+)
+
 // 赞助激活码
 type SponsorActivationCode struct {
 	ID        uint   `gorm:"primaryKey"`
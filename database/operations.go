@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -22,7 +23,7 @@ func GetDB() *gorm.DB {
 		}
 
 		// 自动迁移模型，新增 Announcement
-		err = dbInstance.AutoMigrate(&User{}, &UserStudent{}, &Student{}, &Task{}, &EmailVerificationCode{}, &SponsorActivationCode{})
+		err = dbInstance.AutoMigrate(&User{}, &UserSession{}, &UserBackupCode{}, &PersonalAccessToken{}, &UserStudent{}, &Student{}, &StudentBindRequest{}, &StudentAuditLog{}, &Task{}, &EmailVerificationCode{}, &SponsorActivationCode{}, &CaptchaSample{}, &CaptchaStat{}, &AuditEvent{})
 		if err != nil {
 			panic(fmt.Sprintf("自动迁移失败: %v", err))
 		}
@@ -30,7 +31,10 @@ func GetDB() *gorm.DB {
 	return dbInstance
 }
 
-// SaveStudentOrUpdate 保存或更新学生信息
+// ErrNotStudentOwner 学号已有其他所有者，不能覆盖其登录凭据
+var ErrNotStudentOwner = errors.New("只有该学号的所有者才能更新登录凭据")
+
+// SaveStudentOrUpdate 保存或更新学生信息；学号已有所有者时，只有所有者（student.OwnerUserID）能覆盖
 func SaveStudentOrUpdate(student *Student) error {
 	db := GetDB()
 
@@ -47,8 +51,24 @@ func SaveStudentOrUpdate(student *Student) error {
 		return err
 	}
 
+	if existing.OwnerUserID != nil && (student.OwnerUserID == nil || *student.OwnerUserID != *existing.OwnerUserID) {
+		return ErrNotStudentOwner
+	}
+	if existing.OwnerUserID == nil {
+		existing.OwnerUserID = student.OwnerUserID
+	}
+
+	if student.School != "" {
+		existing.School = student.School
+	}
 	existing.Password = student.Password
 	existing.Cookies = student.Cookies
+	existing.SessionExpiresAt = student.SessionExpiresAt
+	existing.SessionStatus = student.SessionStatus
+	existing.SessionCheckedAt = student.SessionCheckedAt
+	existing.LastRefreshAt = student.LastRefreshAt
+	existing.LastRefreshOK = student.LastRefreshOK
+	existing.LastRefreshError = student.LastRefreshError
 	existing.LastLogin = time.Now()
 	existing.Name = student.Name
 
@@ -78,6 +98,19 @@ func BindUserAndStudent(userID int, stuID string, name string) error {
 	return nil
 }
 
+// RecordStudentAudit 记录一条学号审计日志，失败只打日志不影响业务
+func RecordStudentAudit(db *gorm.DB, stuID string, userID int, action, detail string) {
+	entry := StudentAuditLog{
+		StuID:  stuID,
+		UserID: userID,
+		Action: action,
+		Detail: detail,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("⚠️ 记录学号审计日志失败 %s %s: %v", stuID, action, err)
+	}
+}
+
 // GetStudentByStuID 根据学号查找 student 信息，使用 GetDB()
 func GetStudentByStuID(stuID string) (*Student, error) {
 	db := GetDB()
@@ -0,0 +1,86 @@
+// database/roles.go
+package database
+
+import "fmt"
+
+// Role 用户角色，数值与历史数据保持一致
+type Role int
+
+const (
+	RoleAdmin   Role = 0 // 管理员
+	RoleUser    Role = 1 // 普通用户
+	RoleSponsor Role = 2 // 赞助用户
+)
+
+// Permission 权限标识
+type Permission string
+
+const (
+	PermAdminAccess   Permission = "admin:access"   // 访问 /admin 接口
+	PermManageUsers   Permission = "users:manage"   // 管理用户（解锁、修改角色）
+	PermManageCaptcha Permission = "captcha:manage" // 人工识别验证码、管理样本与统计
+	PermViewAudit     Permission = "audit:view"     // 查询安全审计日志
+)
+
+// TokenScope 个人访问令牌的权限范围
+type TokenScope string
+
+const (
+	ScopeTasksRead  TokenScope = "tasks:read"  // 查询已绑定学号、活动列表与签到任务
+	ScopeTasksWrite TokenScope = "tasks:write" // 创建、修改、删除签到任务
+	ScopeTasksRun   TokenScope = "tasks:run"   // 立即执行签到任务
+)
+
+// TokenScopes 所有可申请的令牌权限范围
+var TokenScopes = []TokenScope{ScopeTasksRead, ScopeTasksWrite, ScopeTasksRun}
+
+// Valid 是否为已定义的权限范围
+func (s TokenScope) Valid() bool {
+	for _, scope := range TokenScopes {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
+
+// roleInfo 角色的显示名称、权限与可绑定学生数量（-1 表示不限）
+type roleInfo struct {
+	name        string
+	permissions []Permission
+	bindLimit   int
+}
+
+var roles = map[Role]roleInfo{
+	RoleAdmin:   {name: "管理员", permissions: []Permission{PermAdminAccess, PermManageUsers, PermManageCaptcha, PermViewAudit}, bindLimit: -1},
+	RoleUser:    {name: "普通用户", bindLimit: 2},
+	RoleSponsor: {name: "赞助用户", bindLimit: 12},
+}
+
+// Valid 是否为已定义的角色
+func (r Role) Valid() bool {
+	_, ok := roles[r]
+	return ok
+}
+
+func (r Role) String() string {
+	if info, ok := roles[r]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("未知角色(%d)", int(r))
+}
+
+// Has 角色是否拥有某项权限
+func (r Role) Has(p Permission) bool {
+	for _, perm := range roles[r].permissions {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// BindLimit 角色最多可绑定的学生数量，-1 表示不限；未知角色返回 0
+func (r Role) BindLimit() int {
+	return roles[r].bindLimit
+}
@@ -0,0 +1,178 @@
+// external/egress/egress.go
+package egress
+
+import (
+	"context"
+	"dormcheck/config"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// proxyEntry 出口代理及其健康状况
+type proxyEntry struct {
+	url       *url.URL
+	transport *http.Transport
+
+	failures  int       // 连续失败次数
+	downUntil time.Time // 被判定不可用的截止时间
+}
+
+func (p *proxyEntry) healthy(now time.Time) bool {
+	return now.After(p.downUntil)
+}
+
+var (
+	proxies  []*proxyEntry
+	pins     = make(map[string]int) // 学号 -> 固定使用的代理下标
+	mu       sync.Mutex
+	initOnce sync.Once
+
+	directTransport = http.DefaultTransport.(*http.Transport).Clone()
+)
+
+// InitEgress 根据配置初始化出口代理池，未配置代理时所有请求直连
+func InitEgress() {
+	initOnce.Do(func() {
+		for _, raw := range config.PlatformProxies {
+			u, err := url.Parse(raw)
+			if err != nil {
+				log.Fatalf("❌ 代理地址格式错误: %s (%v)", raw, err)
+			}
+			transport, err := newProxyTransport(u)
+			if err != nil {
+				log.Fatalf("❌ 代理初始化失败: %s (%v)", u.Redacted(), err)
+			}
+			proxies = append(proxies, &proxyEntry{url: u, transport: transport})
+		}
+
+		if len(proxies) == 0 {
+			log.Println("🌐 未配置出口代理，平台请求将直连")
+		} else {
+			log.Printf("🌐 已加载 %d 个出口代理", len(proxies))
+		}
+	})
+}
+
+// newProxyTransport 为 http/https/socks5 代理创建独立的 Transport
+func newProxyTransport(u *url.URL) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch u.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 代理不支持 DialContext")
+		}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return contextDialer.DialContext(ctx, network, addr)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+
+	return transport, nil
+}
+
+// Client 返回某个学号专用的 HTTP 客户端：同一学号固定走同一个出口（粘性），出口不可用时自动切换
+func Client(stuID string) *http.Client {
+	InitEgress()
+	return &http.Client{
+		Transport: &stickyTransport{key: stuID},
+		Timeout:   30 * time.Second,
+	}
+}
+
+// stickyTransport 按学号选择出口代理，并根据请求结果更新代理健康状况
+type stickyTransport struct {
+	key string
+}
+
+func (t *stickyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idx := pick(t.key)
+	if idx < 0 {
+		return directTransport.RoundTrip(req)
+	}
+
+	resp, err := proxies[idx].transport.RoundTrip(req)
+	report(idx, err)
+
+	// GET 请求可安全重放：当前出口失败时立即换一个出口重试一次
+	if err != nil && req.Method == http.MethodGet {
+		if next := pick(t.key); next >= 0 && next != idx {
+			resp, err = proxies[next].transport.RoundTrip(req)
+			report(next, err)
+		}
+	}
+	return resp, err
+}
+
+// pick 返回学号当前应使用的代理下标；未配置代理时返回 -1
+func pick(key string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(proxies) == 0 {
+		return -1
+	}
+
+	now := time.Now()
+	idx, ok := pins[key]
+	if !ok {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		idx = int(h.Sum32() % uint32(len(proxies)))
+	}
+	if proxies[idx].healthy(now) {
+		pins[key] = idx
+		return idx
+	}
+
+	// 当前出口不可用，按顺序寻找下一个健康的出口并重新固定
+	for i := 1; i < len(proxies); i++ {
+		next := (idx + i) % len(proxies)
+		if proxies[next].healthy(now) {
+			log.Printf("🔀 学号 %s 的出口代理切换: %s -> %s", key, proxies[idx].url.Redacted(), proxies[next].url.Redacted())
+			pins[key] = next
+			return next
+		}
+	}
+
+	// 全部不可用时仍使用原出口，避免请求直接失败
+	return idx
+}
+
+// report 记录一次请求结果：连续失败达到阈值后暂时下线该代理
+func report(idx int, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := proxies[idx]
+	if err == nil {
+		if p.failures >= config.ProxyFailThreshold {
+			log.Printf("✅ 出口代理已恢复: %s", p.url.Redacted())
+		}
+		p.failures = 0
+		return
+	}
+
+	p.failures++
+	if p.failures >= config.ProxyFailThreshold {
+		p.downUntil = time.Now().Add(config.ProxyCooldown)
+		log.Printf("⚠️ 出口代理连续失败 %d 次，暂停使用 %s: %s (%v)", p.failures, config.ProxyCooldown, p.url.Redacted(), err)
+	}
+}
@@ -0,0 +1,257 @@
+// external/platform/guard.go
+package platform
+
+import (
+	"context"
+	"dormcheck/config"
+	"dormcheck/external/schoollogin"
+	"dormcheck/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 平台连续异常，熔断器已打开，暂停向平台发送请求
+var ErrCircuitOpen = errors.New("学校平台暂时不可用，请求已暂停")
+
+// 熔断器状态
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// tokenBucket 简单令牌桶，限制对平台的整体请求速率
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // 每秒补充的令牌数
+	last     time.Time
+}
+
+// Wait 阻塞直到取得一个令牌
+func (b *tokenBucket) Wait() {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+// breaker 熔断器：连续失败达到阈值后打开，冷却后放行一个试探请求
+type breaker struct {
+	school string
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trialing bool // 半开状态下是否已有试探请求在途
+	alerted  bool // 本次故障是否已通知管理员
+}
+
+// allow 判断当前是否允许发出请求
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < config.BreakerOpenDuration {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.trialing = true
+		log.Printf("🟡 平台 %s 熔断冷却结束，放行试探请求", b.school)
+		return true
+	case breakerHalfOpen:
+		if b.trialing {
+			return false
+		}
+		b.trialing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record 记录一次请求结果
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isOutage(err) {
+		if b.state != breakerClosed {
+			log.Printf("🟢 平台 %s 已恢复，熔断器关闭", b.school)
+			if b.alerted {
+				go sendBreakerAlert(b.school, false)
+			}
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		b.trialing = false
+		b.alerted = false
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= config.BreakerFailThreshold {
+		if b.state != breakerOpen {
+			log.Printf("🔴 平台 %s 连续失败 %d 次，熔断器打开 %s: %v", b.school, b.failures, config.BreakerOpenDuration, err)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		b.trialing = false
+
+		// 同一次故障只通知一次管理员
+		if !b.alerted {
+			b.alerted = true
+			go sendBreakerAlert(b.school, true)
+		}
+	}
+}
+
+// isOutage 判断错误是否说明平台本身异常：只有网络错误、超时和 5xx 计入熔断，
+// 验证码错误、登录被拒、登录态失效、响应格式不对等都属于业务结果
+func isOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, schoollogin.ErrServerError) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sendBreakerAlert(school string, opened bool) {
+	subject := fmt.Sprintf("【DormCheck】学校平台 %s 已恢复", school)
+	body := fmt.Sprintf(`<p>学校平台 <strong>%s</strong> 请求已恢复正常，签到与登录态刷新将继续执行。</p>`, school)
+	if opened {
+		subject = fmt.Sprintf("【DormCheck】学校平台 %s 连续请求失败，已暂停访问", school)
+		body = fmt.Sprintf(`<p>学校平台 <strong>%s</strong> 连续 %d 次请求失败，熔断器已打开。</p>
+			<p>在平台恢复前，签到任务与 cookies 刷新将被推迟，不计入失败次数。</p>`, school, config.BreakerFailThreshold)
+	}
+
+	if err := utils.SendAdminAlert(subject, body); err != nil {
+		log.Printf("❌ 发送熔断告警邮件失败: %v", err)
+	}
+}
+
+var (
+	buckets  = make(map[string]*tokenBucket)
+	breakers = make(map[string]*breaker)
+	guardMu  sync.Mutex
+)
+
+// guardFor 返回学校共用的令牌桶和熔断器
+func guardFor(school string) (*tokenBucket, *breaker) {
+	guardMu.Lock()
+	defer guardMu.Unlock()
+
+	if _, ok := buckets[school]; !ok {
+		buckets[school] = &tokenBucket{
+			tokens:   float64(config.PlatformRateBurst),
+			capacity: float64(config.PlatformRateBurst),
+			rate:     config.PlatformRateLimit,
+			last:     time.Now(),
+		}
+		breakers[school] = &breaker{school: school, state: breakerClosed}
+	}
+	return buckets[school], breakers[school]
+}
+
+// CircuitOpen 判断学校平台当前是否处于熔断状态（供调度器决定是否推迟任务）
+func CircuitOpen(school string) bool {
+	if school == "" {
+		school = DefaultSchool
+	}
+	_, b := guardFor(school)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerOpen && time.Since(b.openedAt) < config.BreakerOpenDuration
+}
+
+// guardedPlatform 在平台适配器外加上限流与熔断
+type guardedPlatform struct {
+	inner   Platform
+	bucket  *tokenBucket
+	breaker *breaker
+}
+
+func newGuardedPlatform(school string, inner Platform) Platform {
+	bucket, b := guardFor(school)
+	return guardedPlatform{inner: inner, bucket: bucket, breaker: b}
+}
+
+// do 执行一次受保护的平台调用
+func (g guardedPlatform) do(call func() error) error {
+	if !g.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	g.bucket.Wait()
+
+	err := call()
+	g.breaker.record(err)
+	return err
+}
+
+func (g guardedPlatform) GetCaptcha() (img string, cookies []*http.Cookie, err error) {
+	err = g.do(func() error {
+		img, cookies, err = g.inner.GetCaptcha()
+		return err
+	})
+	return img, cookies, err
+}
+
+func (g guardedPlatform) Login(stuID, password, valCode string, preCookies []*http.Cookie) (cookies []*http.Cookie, err error) {
+	err = g.do(func() error {
+		cookies, err = g.inner.Login(stuID, password, valCode, preCookies)
+		return err
+	})
+	return cookies, err
+}
+
+func (g guardedPlatform) ListActivities(cookies []*http.Cookie) (activities []schoollogin.Activity, err error) {
+	err = g.do(func() error {
+		activities, err = g.inner.ListActivities(cookies)
+		return err
+	})
+	return activities, err
+}
+
+func (g guardedPlatform) SubmitSignIn(cookies []*http.Cookie, req SignInRequest) (result *SignInResult, err error) {
+	err = g.do(func() error {
+		result, err = g.inner.SubmitSignIn(cookies, req)
+		return err
+	})
+	return result, err
+}
+
+func (g guardedPlatform) FetchProfile(cookies []*http.Cookie) (profile *Profile, err error) {
+	err = g.do(func() error {
+		profile, err = g.inner.FetchProfile(cookies)
+		return err
+	})
+	return profile, err
+}
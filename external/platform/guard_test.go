@@ -0,0 +1,37 @@
+// external/platform/guard_test.go
+package platform
+
+import (
+	"context"
+	"dormcheck/external/schoollogin"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestIsOutage(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"成功", nil, false},
+		{"验证码错误", ErrCaptchaWrong, false},
+		{"登录被拒", fmt.Errorf("%w: 密码错误", ErrLoginRejected), false},
+		{"登录页", sessionError(fmt.Errorf("响应解析失败: %w: invalid character '<'", schoollogin.ErrNotJSON)), false},
+		{"5xx", fmt.Errorf("%w: HTTP 502", schoollogin.ErrServerError), true},
+		{"网络错误", fmt.Errorf("请求失败: %w", &url.Error{Op: "Post", URL: "http://x", Err: errors.New("connection refused")}), true},
+		{"超时", fmt.Errorf("请求失败: %w", context.DeadlineExceeded), true},
+		{"其他错误", errors.New("未能获取有效的 ct_vali cookie"), false},
+	}
+	for _, tc := range cases {
+		if got := isOutage(tc.err); got != tc.want {
+			t.Errorf("%s: isOutage = %v，期望 %v", tc.name, got, tc.want)
+		}
+	}
+
+	if !errors.Is(sessionError(fmt.Errorf("x: %w", schoollogin.ErrNotJSON)), ErrSessionExpired) {
+		t.Error("非 JSON 响应应转换为 ErrSessionExpired")
+	}
+}
@@ -0,0 +1,104 @@
+// external/platform/platform.go
+package platform
+
+import (
+	"dormcheck/external/egress"
+	"dormcheck/external/schoollogin"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// DefaultSchool 未指定学校时使用的平台（西南医科大学微学工）
+const DefaultSchool = "swmu"
+
+// ErrCaptchaWrong 平台提示验证码错误，调用方可重新获取验证码后重试
+var ErrCaptchaWrong = errors.New("验证码错误")
+
+// ErrLoginRejected 平台拒绝了登录（如账号或密码错误），重试无意义
+var ErrLoginRejected = errors.New("平台拒绝登录")
+
+// ErrSessionExpired 登录态已失效，需要重新登录
+var ErrSessionExpired = errors.New("登录态已失效")
+
+// SignInRequest 签到请求参数
+type SignInRequest struct {
+	ActivityID string
+	Address    string
+	Longitude  float64
+	Latitude   float64
+}
+
+// SignInResult 签到结果
+type SignInResult struct {
+	Success bool   // 平台是否判定签到成功（含“已签到”）
+	Message string // 平台返回的提示信息
+}
+
+// Profile 学生在平台上的基本资料
+type Profile struct {
+	Name string
+}
+
+// Platform 学校签到平台适配器，每个学校注册一个实现
+type Platform interface {
+	// GetCaptcha 获取登录验证码，返回 base64 图像（带 data URI 前缀）和登录前的 cookies
+	GetCaptcha() (string, []*http.Cookie, error)
+	// Login 使用账号密码和验证码登录，返回登录态 cookies；
+	// 验证码错误时返回 ErrCaptchaWrong，平台拒绝登录（账号密码错误等）时返回 ErrLoginRejected
+	Login(stuID, password, valCode string, preCookies []*http.Cookie) ([]*http.Cookie, error)
+	// ListActivities 查询可签到活动
+	ListActivities(cookies []*http.Cookie) ([]schoollogin.Activity, error)
+	// SubmitSignIn 提交签到
+	SubmitSignIn(cookies []*http.Cookie, req SignInRequest) (*SignInResult, error)
+	// FetchProfile 获取学生资料，登录态失效时返回 ErrSessionExpired；也用作轻量的登录态探测
+	FetchProfile(cookies []*http.Cookie) (*Profile, error)
+}
+
+// Factory 使用给定的 HTTP 客户端（已绑定学生的出口代理）创建平台适配器
+type Factory func(client *http.Client) Platform
+
+var (
+	registry = map[string]Factory{
+		DefaultSchool: newSWMUPlatform,
+	}
+	registryMu sync.RWMutex
+)
+
+// Register 注册（或替换）某个学校的平台适配器
+func Register(school string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[school] = factory
+}
+
+// For 返回学校对应的平台适配器，该适配器的所有请求都经由学号固定的出口发出；school 为空时使用默认学校
+func For(school, stuID string) (Platform, error) {
+	if school == "" {
+		school = DefaultSchool
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[school]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("不支持的学校: %s", school)
+	}
+	return newGuardedPlatform(school, factory(egress.Client(stuID))), nil
+}
+
+// Schools 返回所有已注册的学校标识
+func Schools() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	schools := make([]string, 0, len(registry))
+	for school := range registry {
+		schools = append(schools, school)
+	}
+	sort.Strings(schools)
+	return schools
+}
@@ -0,0 +1,82 @@
+// external/platform/swmu.go
+package platform
+
+import (
+	"dormcheck/external/schoollogin"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// swmuPlatform 西南医科大学微学工平台，直接复用 schoollogin 中的请求实现
+type swmuPlatform struct {
+	client *http.Client
+}
+
+func newSWMUPlatform(client *http.Client) Platform {
+	return swmuPlatform{client: client}
+}
+
+func (s swmuPlatform) GetCaptcha() (string, []*http.Cookie, error) {
+	return schoollogin.GetValidateCodeBase64(s.client)
+}
+
+func (s swmuPlatform) Login(stuID, password, valCode string, preCookies []*http.Cookie) ([]*http.Cookie, error) {
+	result, err := schoollogin.Login(s.client, stuID, password, valCode, preCookies)
+	if err != nil {
+		if strings.Contains(err.Error(), "验证码") || strings.Contains(err.Error(), "ValCode") {
+			return nil, fmt.Errorf("%w: %v", ErrCaptchaWrong, err)
+		}
+		if result != nil && result.Response != nil {
+			// 平台正常响应但拒绝登录
+			return nil, fmt.Errorf("%w: %v", ErrLoginRejected, err)
+		}
+		return nil, err
+	}
+	return result.Cookies, nil
+}
+
+func (s swmuPlatform) ListActivities(cookies []*http.Cookie) ([]schoollogin.Activity, error) {
+	activities, err := schoollogin.GetActivityList(s.client, cookies)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	return activities, nil
+}
+
+func (s swmuPlatform) SubmitSignIn(cookies []*http.Cookie, req SignInRequest) (*SignInResult, error) {
+	resp, err := schoollogin.SubmitSignin(s.client, cookies, schoollogin.SigninForm{
+		ActivityID: req.ActivityID,
+		Address:    req.Address,
+		Longitude:  req.Longitude,
+		Latitude:   req.Latitude,
+	})
+	if err != nil {
+		return nil, sessionError(err)
+	}
+
+	return &SignInResult{
+		Success: resp.IsOk || resp.Msg == "该活动已经签到成功",
+		Message: resp.Msg,
+	}, nil
+}
+
+func (s swmuPlatform) FetchProfile(cookies []*http.Cookie) (*Profile, error) {
+	name, err := schoollogin.GetStudentNameFromDetail(s.client, cookies)
+	if err != nil {
+		if errors.Is(err, schoollogin.ErrDetailNoUserName) {
+			return nil, fmt.Errorf("%w: %v", ErrSessionExpired, err)
+		}
+		return nil, err
+	}
+	return &Profile{Name: name}, nil
+}
+
+// sessionError 登录态失效后平台接口会返回登录页（HTML）而不是 JSON，转换为 ErrSessionExpired
+func sessionError(err error) error {
+	if errors.Is(err, schoollogin.ErrNotJSON) {
+		return fmt.Errorf("%w: %v", ErrSessionExpired, err)
+	}
+	return err
+}
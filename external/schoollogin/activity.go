@@ -19,7 +19,7 @@ type Activity struct {
 	ForeachpEndday    string `json:"foreachp_endday"`
 }
 
-func GetActivityList(cookies []*http.Cookie) ([]Activity, error) {
+func GetActivityList(client *http.Client, cookies []*http.Cookie) ([]Activity, error) {
 	url := "http://plat.swmu.edu.cn/studentwork/PunchMStudent/GetActivityList"
 
 	// 构造请求
@@ -38,19 +38,20 @@ func GetActivityList(cookies []*http.Cookie) ([]Activity, error) {
 	}
 	req.Header.Set("Cookie", cookieStr)
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
-
 	// 假设响应是 JSON 格式，里面有 data 字段是活动列表
 	var respData struct {
 		Data []Activity `json:"data"`
@@ -60,9 +61,8 @@ func GetActivityList(cookies []*http.Cookie) ([]Activity, error) {
 
 	err = json.Unmarshal(body, &respData)
 	if err != nil {
-		return nil, fmt.Errorf("解析活动列表失败: %v", err)
+		return nil, fmt.Errorf("解析活动列表失败: %w: %v", ErrNotJSON, err)
 	}
 
-
 	return respData.Data, nil
 }
@@ -13,7 +13,7 @@ import (
 )
 
 // GetValidateCodeBase64 返回 base64 验证码图像（带 data URI 前缀）、包含 Vlis 和 VK_ 的 cookies
-func GetValidateCodeBase64() (base64Img string, cookies []*http.Cookie, err error) {
+func GetValidateCodeBase64(client *http.Client) (base64Img string, cookies []*http.Cookie, err error) {
 	// 当前13位毫秒时间戳
 	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
 	url := fmt.Sprintf("http://plat.swmu.edu.cn/Authentication/GetValidateCode?v=%d", timestamp)
@@ -23,16 +23,21 @@ func GetValidateCodeBase64() (base64Img string, cookies []*http.Cookie, err erro
 		return "", nil, fmt.Errorf("创建cookiejar失败: %w", err)
 	}
 
-	client := &http.Client{
-		Jar:     jar,
-		Timeout: 10 * time.Second, // 设置超时
+	// 沿用调用方的出口（Transport），使用独立的 cookiejar
+	captchaClient := &http.Client{
+		Jar:       jar,
+		Transport: client.Transport,
+		Timeout:   10 * time.Second, // 设置超时
 	}
 
-	resp, err := client.Get(url)
+	resp, err := captchaClient.Get(url)
 	if err != nil {
 		return "", nil, fmt.Errorf("请求验证码失败: %w", err)
 	}
 	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", nil, err
+	}
 
 	imgBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -1,6 +1,26 @@
 // external/schoollogin/client.go
 package schoollogin
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrServerError 平台返回 5xx，说明平台本身异常
+var ErrServerError = errors.New("平台服务器错误")
+
+// ErrNotJSON 接口返回的不是 JSON，通常是登录态失效后被重定向到了登录页
+var ErrNotJSON = errors.New("响应不是 JSON")
+
+// checkStatus 平台返回 5xx 时返回 ErrServerError
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: HTTP %d", ErrServerError, resp.StatusCode)
+	}
+	return nil
+}
+
 type SimpleCookie struct {
 	Name  string
 	Value string
@@ -8,8 +8,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // LoginResponse 代表登录接口响应的数据结构（可根据实际API调整）
@@ -29,7 +34,7 @@ type LoginResult struct {
 }
 
 // Login 进行登录，返回封装好的登录结果和错误
-func Login(username, password, valCode string, preCookies []*http.Cookie) (*LoginResult, error) {
+func Login(client *http.Client, username, password, valCode string, preCookies []*http.Cookie) (*LoginResult, error) {
 	// 1. RSA 加密用户名和密码
 	encUser, err := utils.EncryptWithRSA(username)
 	if err != nil {
@@ -51,36 +56,48 @@ func Login(username, password, valCode string, preCookies []*http.Cookie) (*Logi
 	}
 
 	// 3. 创建请求
-	req, err := http.NewRequest("POST", "http://plat.swmu.edu.cn/MyAuthentication/put/", strings.NewReader(form.Encode()))
+	loginURL := "http://plat.swmu.edu.cn/MyAuthentication/put/"
+	req, err := http.NewRequest("POST", loginURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
-
-	// 4. 添加请求头和 cookie
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	var cookieStrings []string
+
+	// 4. 使用 cookiejar 管理整个登录过程中的 cookie，并预先放入 Vlis 和 VK_
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("创建cookiejar失败: %v", err)
+	}
+	var validateCookies []*http.Cookie
 	for _, ck := range preCookies {
 		if ck.Name == "Vlis" || ck.Name == "VK_" {
-			cookieStrings = append(cookieStrings, ck.Name+"="+ck.Value)
+			validateCookies = append(validateCookies, ck)
 		}
 	}
-	if len(cookieStrings) > 0 {
-		cookieHeader := strings.Join(cookieStrings, "; ")
-		req.Header.Set("Cookie", cookieHeader)
+	jar.SetCookies(req.URL, validateCookies)
+
+	// cookiejar 只返回 name/value，这里额外记录每一跳 Set-Cookie 的完整属性
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
 	}
+	recorder := &setCookieRecorder{base: base}
 
-	// 5. 发起请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 5. 发起请求（沿用调用方的出口和超时设置）
+	loginClient := &http.Client{Jar: jar, Transport: recorder, Timeout: client.Timeout}
+	resp, err := loginClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("请求发送失败: %v", err)
+		return nil, fmt.Errorf("请求发送失败: %w", err)
 	}
 	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
 
 	// 6. 读取响应
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 	// 🐞 打印响应内容
 	log.Println("获取登录响应:", string(bodyBytes))
@@ -96,22 +113,17 @@ func Login(username, password, valCode string, preCookies []*http.Cookie) (*Logi
 		return &LoginResult{Response: &loginResp}, fmt.Errorf("微学工平台提示信息: %s", loginResp.Message)
 	}
 
-	// 9. 提取 Cookies
-	var ctVali string
-	ctValiCount := 0
-	for _, setCookie := range resp.Header["Set-Cookie"] {
-		if strings.HasPrefix(setCookie, "ct_vali=") {
-			ctValiCount++
-			if ctValiCount == 2 {
-				parts := strings.SplitN(setCookie, ";", 2)
-				ctVali = strings.TrimPrefix(parts[0], "ct_vali=")
-				break
-			}
+	// 9. 从 cookiejar 中取出最终生效的 ct_vali（平台会先清除再重新下发），并补全属性
+	var ctVali *http.Cookie
+	for _, ck := range jar.Cookies(req.URL) {
+		if ck.Name == "ct_vali" && ck.Value != "" {
+			ctVali = recorder.withAttributes(ck)
+			break
 		}
 	}
 
 	// 确保获取到有效的 Cookies
-	if ctVali == "" {
+	if ctVali == nil {
 		return nil, fmt.Errorf("未能获取有效的 ct_vali cookie")
 	}
 
@@ -119,7 +131,7 @@ func Login(username, password, valCode string, preCookies []*http.Cookie) (*Logi
 	finalCookies := []*http.Cookie{
 		{Name: "qyuserid", Value: username},
 		{Name: "utpstr", Value: "1"},
-		{Name: "ct_vali", Value: ctVali},
+		ctVali,
 	}
 
 	// 返回封装的登录结果，包括正确的 Cookies
@@ -128,3 +140,47 @@ func Login(username, password, valCode string, preCookies []*http.Cookie) (*Logi
 		Cookies:  finalCookies,
 	}, nil
 }
+
+// setCookieRecorder 记录经过的每个响应中的 Set-Cookie（含 Expires/Domain/Path 等属性）
+type setCookieRecorder struct {
+	base    http.RoundTripper
+	mu      sync.Mutex
+	cookies map[string]*http.Cookie
+}
+
+func (r *setCookieRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cookies == nil {
+		r.cookies = make(map[string]*http.Cookie)
+	}
+	for _, ck := range resp.Cookies() {
+		// 后下发的同名 cookie 覆盖先前的，与 cookiejar 的行为一致
+		r.cookies[ck.Name] = ck
+	}
+	return resp, nil
+}
+
+// withAttributes 为 cookiejar 返回的 cookie 补上记录到的属性
+func (r *setCookieRecorder) withAttributes(ck *http.Cookie) *http.Cookie {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	full := &http.Cookie{Name: ck.Name, Value: ck.Value}
+	if recorded, ok := r.cookies[ck.Name]; ok && recorded.Value == ck.Value {
+		full.Domain = recorded.Domain
+		full.Path = recorded.Path
+		full.Secure = recorded.Secure
+		full.HttpOnly = recorded.HttpOnly
+		full.Expires = recorded.Expires
+		if recorded.MaxAge > 0 {
+			full.Expires = time.Now().Add(time.Duration(recorded.MaxAge) * time.Second)
+		}
+	}
+	return full
+}
@@ -0,0 +1,71 @@
+package schoollogin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SigninForm 签到提交参数
+type SigninForm struct {
+	ActivityID string
+	Address    string
+	Longitude  float64
+	Latitude   float64
+}
+
+// SigninResponse 签到接口响应
+type SigninResponse struct {
+	IsOk bool   `json:"isok"`
+	Msg  string `json:"msg"`
+}
+
+// SubmitSignin 使用已登录的 cookies 提交一次签到
+func SubmitSignin(client *http.Client, cookies []*http.Cookie, signin SigninForm) (*SigninResponse, error) {
+	// 构造请求
+	form := url.Values{
+		"ActivityId":     {signin.ActivityID},
+		"ReasonText":     {""},
+		"guidValue":      {""},
+		"address":        {signin.Address},
+		"longitudeGaoDe": {fmt.Sprintf("%.6f", signin.Longitude)},
+		"latitudeGaoDe":  {fmt.Sprintf("%.5f", signin.Latitude)},
+		"RType":          {"1"},
+	}
+	req, err := http.NewRequest("POST", "http://plat.swmu.edu.cn/studentwork/PunchMStudent/SubmitSignin", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("请求构造失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	// 添加 Cookie
+	var cookieStrs []string
+	for _, ck := range cookies {
+		cookieStrs = append(cookieStrs, ck.Name+"="+ck.Value)
+	}
+	req.Header.Set("Cookie", strings.Join(cookieStrs, "; "))
+
+	// 发送请求
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求发送失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	// 解析响应
+	var result SigninResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("响应解析失败: %w: %v", ErrNotJSON, err)
+	}
+
+	return &result, nil
+}
@@ -9,8 +9,11 @@ import (
 	"strings"
 )
 
+// ErrDetailNoUserName 学生详情页中没有 userName，通常意味着 Cookie 已失效
+var ErrDetailNoUserName = errors.New("无法从页面中解析出 userName")
+
 // GetStudentNameFromDetail 使用已登录的 cookies 请求学生详情页，从 HTML 中提取 userName
-func GetStudentNameFromDetail(cookies []*http.Cookie) (string, error) {
+func GetStudentNameFromDetail(client *http.Client, cookies []*http.Cookie) (string, error) {
 	url := "http://me.swmu.edu.cn/studentwork/StudentManager/Detail"
 
 	// 构建请求
@@ -32,12 +35,14 @@ func GetStudentNameFromDetail(cookies []*http.Cookie) (string, error) {
 	req.Header.Set("Cookie", strings.TrimSpace(cookieStr))
 
 	// 发起请求
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -54,7 +59,7 @@ func GetStudentNameFromDetail(cookies []*http.Cookie) (string, error) {
 	matches := re.FindStringSubmatch(html)
 	if len(matches) < 2 {
 		log.Println("⚠️ 未能在页面中提取 userName。可能是 Cookie 失效。")
-		return "", errors.New("无法从页面中解析出 userName")
+		return "", ErrDetailNoUserName
 	}
 
 	name := strings.TrimSpace(matches[1])
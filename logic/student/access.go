@@ -0,0 +1,31 @@
+// logic/student/access.go
+package student
+
+import (
+	"dormcheck/database"
+	"errors"
+)
+
+// ErrStudentNotBound 当前用户没有绑定该学号，不能查看或操作
+var ErrStudentNotBound = errors.New("无权操作该学号，请先绑定")
+
+// UserBoundToStudent 判断用户是否绑定了某个学号
+func UserBoundToStudent(userID int, stuID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&database.UserStudent{}).
+		Where("user_id = ? AND stu_id = ?", userID, stuID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// EnsureStudentBinding 校验用户已绑定该学号，未绑定时返回 ErrStudentNotBound
+func EnsureStudentBinding(userID int, stuID string) error {
+	bound, err := UserBoundToStudent(userID, stuID)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return ErrStudentNotBound
+	}
+	return nil
+}
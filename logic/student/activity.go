@@ -1,14 +1,35 @@
 package student
 
 import (
+	"dormcheck/config"
 	"dormcheck/database"
+	"dormcheck/external/platform"
 	"dormcheck/external/schoollogin"
 	"dormcheck/utils"
 	"fmt"
+	"sync"
+	"time"
 )
 
-// GetStudentActivityList 查询某个学号的可签到活动
-func GetStudentActivityList(stuID string) ([]schoollogin.Activity, error) {
+// activityCacheEntry 某个学号的活动列表缓存
+type activityCacheEntry struct {
+	activities []schoollogin.Activity
+	fetchedAt  time.Time
+}
+
+var (
+	activityCache   = make(map[string]activityCacheEntry)
+	activityCacheMu sync.Mutex
+)
+
+// GetStudentActivityList 查询某个学号的可签到活动，优先使用缓存；refresh 为 true 时跳过缓存直接请求平台
+func GetStudentActivityList(stuID string, refresh bool) ([]schoollogin.Activity, error) {
+	if !refresh {
+		if activities, ok := getCachedActivities(stuID); ok {
+			return activities, nil
+		}
+	}
+
 	// 查数据库获取 cookies
 	student, err := database.GetStudentByStuID(stuID)
 	if err != nil {
@@ -20,11 +41,43 @@ func GetStudentActivityList(stuID string) ([]schoollogin.Activity, error) {
 		return nil, fmt.Errorf("cookie 解析失败: %v", err)
 	}
 
+	p, err := platform.For(student.School, stuID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 发起请求
-	activities, err := schoollogin.GetActivityList(cookies)
+	activities, err := p.ListActivities(cookies)
 	if err != nil {
 		return nil, fmt.Errorf("获取活动失败: %v", err)
 	}
 
+	activityCacheMu.Lock()
+	activityCache[stuID] = activityCacheEntry{activities: activities, fetchedAt: time.Now()}
+	activityCacheMu.Unlock()
+
 	return activities, nil
 }
+
+// getCachedActivities 读取未过期的活动列表缓存
+func getCachedActivities(stuID string) ([]schoollogin.Activity, bool) {
+	activityCacheMu.Lock()
+	defer activityCacheMu.Unlock()
+
+	entry, ok := activityCache[stuID]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(entry.fetchedAt) > config.ActivityCacheTTL {
+		delete(activityCache, stuID)
+		return nil, false
+	}
+	return entry.activities, true
+}
+
+// InvalidateActivityCache 清除某个学号的活动列表缓存（如重新登录后）
+func InvalidateActivityCache(stuID string) {
+	activityCacheMu.Lock()
+	delete(activityCache, stuID)
+	activityCacheMu.Unlock()
+}
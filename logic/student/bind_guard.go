@@ -0,0 +1,78 @@
+// logic/student/bind_guard.go
+package student
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrBindAttemptsExceeded 申请绑定他人学号的次数过多，已被临时锁定
+var ErrBindAttemptsExceeded = errors.New("申请绑定次数过多")
+
+// bindAttempts 某个用户或学号在统计窗口内的绑定申请次数（仅保存在内存中）
+type bindAttempts struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+var (
+	bindAttemptsByKey = make(map[string]*bindAttempts)
+	bindAttemptsMu    sync.Mutex
+)
+
+// takeBindAttempt 申请绑定他人学号前按用户和学号分别计数，检查与计数在同一把锁内完成；
+// 任一方达到上限后锁定一段时间，并写入学号审计日志
+func takeBindAttempt(userID int, stuID string) error {
+	bindAttemptsMu.Lock()
+	defer bindAttemptsMu.Unlock()
+
+	now := time.Now()
+	// 顺便清理窗口结束且未锁定的记录
+	for k, a := range bindAttemptsByKey {
+		if now.Sub(a.windowStart) > config.BindRequestWindow && now.After(a.lockedUntil) {
+			delete(bindAttemptsByKey, k)
+		}
+	}
+
+	keys := []struct {
+		key   string
+		limit int
+	}{
+		{fmt.Sprintf("user:%d", userID), config.BindRequestUserMaxAttempts},
+		{"stu:" + stuID, config.BindRequestStuMaxAttempts},
+	}
+
+	for _, k := range keys {
+		if a, ok := bindAttemptsByKey[k.key]; ok && now.Before(a.lockedUntil) {
+			return lockedBindError(a.lockedUntil.Sub(now))
+		}
+	}
+
+	for _, k := range keys {
+		a, ok := bindAttemptsByKey[k.key]
+		if !ok || now.Sub(a.windowStart) > config.BindRequestWindow {
+			a = &bindAttempts{windowStart: now}
+			bindAttemptsByKey[k.key] = a
+		}
+		a.count++
+		if a.count >= k.limit {
+			a.lockedUntil = now.Add(config.BindRequestLockDuration)
+			a.count = 0
+			a.windowStart = now
+			log.Printf("🚫 %s 申请绑定学号 %s 次数过多，锁定 %s", k.key, stuID, config.BindRequestLockDuration)
+			database.RecordStudentAudit(database.DB, stuID, userID, database.StudentAuditBindLocked,
+				fmt.Sprintf("%s 达到 %d 次申请上限，锁定 %s", k.key, k.limit, config.BindRequestLockDuration))
+		}
+	}
+	return nil
+}
+
+func lockedBindError(wait time.Duration) error {
+	return fmt.Errorf("%w：已被临时锁定，请 %d 分钟后再试", ErrBindAttemptsExceeded, int(wait.Minutes())+1)
+}
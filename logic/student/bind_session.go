@@ -0,0 +1,178 @@
+// logic/student/bind_session.go
+package student
+
+import (
+	"crypto/rand"
+	"dormcheck/captcha"
+	"dormcheck/database"
+	"dormcheck/external/platform"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// bindSessionTTL 手动输入验证码的绑定会话有效期
+const bindSessionTTL = 5 * time.Minute
+
+// BindChallenge 需要用户手动输入验证码时返回给前端的信息
+type BindChallenge struct {
+	Token   string `json:"bind_token"`
+	Captcha string `json:"captcha"` // base64 图像（带 data URI 前缀），可直接用作 img src
+}
+
+// bindSession 两步绑定的服务端状态，密码与 Vlis/VK_ cookies 只保存在服务端
+type bindSession struct {
+	userID     int
+	school     string
+	stuID      string
+	password   string
+	captcha    string
+	preCookies []*http.Cookie
+	expiresAt  time.Time
+}
+
+var (
+	bindSessions   = make(map[string]*bindSession)
+	bindSessionsMu sync.Mutex
+)
+
+// startBindSession 获取一张验证码并创建绑定会话
+func startBindSession(p platform.Platform, userID int, school, stuID, password string) (*BindChallenge, error) {
+	img, preCookies, err := p.GetCaptcha()
+	if err != nil {
+		return nil, fmt.Errorf("获取验证码失败: %w", err)
+	}
+
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	bindSessionsMu.Lock()
+	defer bindSessionsMu.Unlock()
+
+	// 顺便清理过期会话
+	now := time.Now()
+	for t, s := range bindSessions {
+		if now.After(s.expiresAt) {
+			delete(bindSessions, t)
+		}
+	}
+
+	bindSessions[token] = &bindSession{
+		userID:     userID,
+		school:     school,
+		stuID:      stuID,
+		password:   password,
+		captcha:    img,
+		preCookies: preCookies,
+		expiresAt:  now.Add(bindSessionTTL),
+	}
+
+	return &BindChallenge{Token: token, Captcha: img}, nil
+}
+
+// getBindSession 取出属于该用户且未过期的绑定会话。返回加锁时的副本，
+// 之后 refreshBindSession 更新验证码和 cookies 不会与调用方的读取冲突
+func getBindSession(userID int, token string) (bindSession, error) {
+	bindSessionsMu.Lock()
+	defer bindSessionsMu.Unlock()
+
+	s, ok := bindSessions[token]
+	if !ok || s.userID != userID {
+		return bindSession{}, errors.New("绑定会话不存在，请重新发起绑定")
+	}
+	if time.Now().After(s.expiresAt) {
+		delete(bindSessions, token)
+		return bindSession{}, errors.New("绑定会话已过期，请重新发起绑定")
+	}
+	return *s, nil
+}
+
+// BindSessionStudent 返回绑定会话对应的学号，会话不存在或已过期时返回空字符串
+func BindSessionStudent(userID int, token string) string {
+	s, err := getBindSession(userID, token)
+	if err != nil {
+		return ""
+	}
+	return s.stuID
+}
+
+func deleteBindSession(token string) {
+	bindSessionsMu.Lock()
+	delete(bindSessions, token)
+	bindSessionsMu.Unlock()
+}
+
+// SubmitBindCaptcha 提交用户手动输入的验证码完成绑定；验证码错误时返回新的验证码供用户重试
+func SubmitBindCaptcha(userID int, token, code string) (*BindChallenge, error) {
+	s, err := getBindSession(userID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := platform.For(s.school, s.stuID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, ok := captcha.Normalize(code)
+	if !ok {
+		return nil, fmt.Errorf("验证码格式不正确")
+	}
+
+	loginCookies, err := p.Login(s.stuID, s.password, code, s.preCookies)
+	captcha.RecordSample(s.captcha, code, "user", database.CaptchaSourceBind, captchaAccepted(err))
+	if err != nil {
+		if errors.Is(err, platform.ErrCaptchaWrong) {
+			log.Printf("⚠️ 用户输入的验证码错误，重新获取: 学号=%s", s.stuID)
+			return refreshBindSession(p, token)
+		}
+		deleteBindSession(token)
+		return nil, fmt.Errorf("登录失败: %w", err)
+	}
+
+	deleteBindSession(token)
+	return nil, completeBind(p, s.userID, s.school, s.stuID, s.password, loginCookies)
+}
+
+// RefreshBindCaptcha 为绑定会话换一张验证码
+func RefreshBindCaptcha(userID int, token string) (*BindChallenge, error) {
+	s, err := getBindSession(userID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := platform.For(s.school, s.stuID)
+	if err != nil {
+		return nil, err
+	}
+	return refreshBindSession(p, token)
+}
+
+// refreshBindSession 获取新的验证码和 Vlis/VK_ cookies 并延长会话有效期
+func refreshBindSession(p platform.Platform, token string) (*BindChallenge, error) {
+	img, preCookies, err := p.GetCaptcha()
+	if err != nil {
+		return nil, fmt.Errorf("获取验证码失败: %w", err)
+	}
+
+	bindSessionsMu.Lock()
+	s, ok := bindSessions[token]
+	if ok {
+		s.captcha = img
+		s.preCookies = preCookies
+		s.expiresAt = time.Now().Add(bindSessionTTL)
+	}
+	bindSessionsMu.Unlock()
+	if !ok {
+		return nil, errors.New("绑定会话不存在，请重新发起绑定")
+	}
+
+	return &BindChallenge{Token: token, Captcha: img}, nil
+}
@@ -0,0 +1,231 @@
+// logic/student/credentials.go
+package student
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/external/platform"
+	"dormcheck/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UpdateStudentCredentials 学生修改了学校平台密码后，由所有者提交新密码：
+// 先用新密码真实登录一次验证，成功后更新密码与登录态，并重新启用该学号下失败的签到任务，返回重新启用的任务数
+func UpdateStudentCredentials(userID int, stuID, plainPassword string) (int64, error) {
+	var stu database.Student
+	if err := database.DB.First(&stu, "stu_id = ?", stuID).Error; err != nil {
+		return 0, errors.New("学号不存在")
+	}
+	if err := EnsureStudentBinding(userID, stuID); err != nil {
+		return 0, err
+	}
+	if stu.OwnerUserID != nil && *stu.OwnerUserID != userID {
+		return 0, database.ErrNotStudentOwner
+	}
+
+	cookies, err := LoginWithoutBind(stu.School, stuID, plainPassword, database.CaptchaSourceBind)
+	if errors.Is(err, platform.ErrLoginRejected) {
+		return 0, errors.New("新密码登录失败，请确认学校平台密码是否正确")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var rearmed int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 登录验证期间其他用户可能已成为所有者，或后台刷新已改写该行，需重新读取并再次校验所有者
+		var stu database.Student
+		if err := tx.First(&stu, "stu_id = ?", stuID).Error; err != nil {
+			return errors.New("学号不存在")
+		}
+		if stu.OwnerUserID != nil && *stu.OwnerUserID != userID {
+			return database.ErrNotStudentOwner
+		}
+
+		wasInvalid := stu.CredentialsInvalid
+		stu.Password = plainPassword
+		stu.OwnerUserID = &userID
+		ApplySession(&stu, cookies)
+		RecordRefreshResult(&stu, nil)
+		stu.CredentialFailures = 0
+		stu.CredentialsInvalid = false
+		stu.CredentialsInvalidAt = nil
+
+		// 只更新本次改动的列；按结构体更新以便密码和 cookies 经过加密序列化器
+		if err := tx.Model(&stu).Select(
+			"password", "owner_user_id", "cookies", "last_login", "session_expires_at",
+			"session_status", "session_checked_at", "last_refresh_at", "last_refresh_ok", "last_refresh_error",
+			"credential_failures", "credentials_invalid", "credentials_invalid_at",
+		).Updates(&stu).Error; err != nil {
+			return fmt.Errorf("保存学生信息失败: %v", err)
+		}
+
+		// 所有用户为该学号创建的失败任务都重新排队，等待调度器重新执行
+		result := tx.Model(&database.Task{}).
+			Where("stu_id = ? AND exec_status = ?", stuID, "failed").
+			Updates(map[string]interface{}{
+				"exec_status": "pending",
+				"retry_count": 0,
+				"last_error":  "",
+			})
+		if result.Error != nil {
+			return fmt.Errorf("重新启用任务失败: %v", result.Error)
+		}
+		rearmed = result.RowsAffected
+
+		if wasInvalid {
+			if err := resumeSuspendedTasks(tx, stuID); err != nil {
+				return err
+			}
+		}
+
+		database.RecordStudentAudit(tx, stuID, userID, database.StudentAuditCredentials,
+			fmt.Sprintf("新密码登录验证通过，重新启用 %d 个失败任务", rearmed))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	InvalidateActivityCache(stuID)
+
+	log.Printf("🔑 学号 %s 的登录密码已更新，重新启用 %d 个失败任务", stuID, rearmed)
+	return rearmed, nil
+}
+
+// RecordCredentialResult 记录一次自动登录结果：密码被平台拒绝时累计连续失败次数，
+// 达到阈值后判定密码失效、暂停该学号的任务并通知所有绑定用户（只通知一次）；登录成功时清零
+func RecordCredentialResult(stu *database.Student, loginErr error) {
+	db := database.DB
+
+	if loginErr == nil {
+		if stu.CredentialFailures > 0 {
+			stu.CredentialFailures = 0
+			db.Model(&database.Student{}).Where("stu_id = ?", stu.StuID).Update("credential_failures", 0)
+		}
+		return
+	}
+	// 验证码、网络等其他错误不能说明密码有问题
+	if !errors.Is(loginErr, platform.ErrLoginRejected) {
+		return
+	}
+
+	if err := db.Model(&database.Student{}).Where("stu_id = ?", stu.StuID).
+		Update("credential_failures", gorm.Expr("COALESCE(credential_failures, 0) + 1")).Error; err != nil {
+		log.Printf("❌ 记录密码失败次数失败: 学号=%s, 错误=%v", stu.StuID, err)
+		return
+	}
+	if err := db.Model(&database.Student{}).Select("credential_failures").
+		Where("stu_id = ?", stu.StuID).Scan(&stu.CredentialFailures).Error; err != nil {
+		return
+	}
+	log.Printf("⚠️ 学号 %s 的密码被平台拒绝，连续 %d 次", stu.StuID, stu.CredentialFailures)
+
+	if stu.CredentialFailures < config.CredentialFailThreshold {
+		return
+	}
+	if err := suspendStudent(stu); err != nil {
+		log.Printf("❌ 暂停学号任务失败: 学号=%s, 错误=%v", stu.StuID, err)
+	}
+}
+
+// suspendStudent 判定学号密码失效并暂停其所有任务，已判定过的不重复处理
+func suspendStudent(stu *database.Student) error {
+	var paused int64
+	suspended := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&database.Student{}).
+			Where("stu_id = ? AND (credentials_invalid IS NULL OR credentials_invalid = ?)", stu.StuID, false).
+			Updates(map[string]interface{}{
+				"credentials_invalid":    true,
+				"credentials_invalid_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		suspended = true
+		stu.CredentialsInvalid = true
+		stu.CredentialsInvalidAt = &now
+
+		result = tx.Model(&database.Task{}).
+			Where("stu_id = ? AND COALESCE(paused_reason, '') = ''", stu.StuID).
+			Update("paused_reason", database.TaskPausedCredentials)
+		if result.Error != nil {
+			return result.Error
+		}
+		paused = result.RowsAffected
+
+		database.RecordStudentAudit(tx, stu.StuID, 0, database.StudentAuditCredentialsInvalid,
+			fmt.Sprintf("密码连续 %d 次被拒绝，暂停 %d 个任务", stu.CredentialFailures, paused))
+		return nil
+	})
+	if err != nil || !suspended {
+		return err
+	}
+
+	log.Printf("🔒 学号 %s 的密码已判定失效，暂停 %d 个签到任务", stu.StuID, paused)
+	notifyCredentialsInvalid(stu, paused)
+	return nil
+}
+
+// sendCredentialsInvalidEmail 发送密码失效通知，测试中替换以免真正发信
+var sendCredentialsInvalidEmail = utils.SendCredentialsInvalidEmail
+
+// notifyCredentialsInvalid 通知所有绑定该学号的用户
+func notifyCredentialsInvalid(stu *database.Student, paused int64) {
+	var users []database.User
+	if err := database.DB.
+		Where("id IN (?)", database.DB.Model(&database.UserStudent{}).Select("user_id").Where("stu_id = ?", stu.StuID)).
+		Find(&users).Error; err != nil {
+		log.Printf("❌ 查询学号绑定用户失败: %v", err)
+		return
+	}
+
+	for _, u := range users {
+		go func(u database.User) {
+			if err := sendCredentialsInvalidEmail(u.Email, u.Username, stu.StuID, stu.Name, paused); err != nil {
+				log.Printf("❌ 发送密码失效通知失败: %v", err)
+			}
+		}(u)
+	}
+}
+
+// clearCredentialsInvalid 所有者重新登录绑定成功后清除失效标记，并恢复暂停的任务
+func clearCredentialsInvalid(stuID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Student{}).
+			Where("stu_id = ? AND credentials_invalid = ?", stuID, true).
+			Updates(map[string]interface{}{
+				"credential_failures":    0,
+				"credentials_invalid":    false,
+				"credentials_invalid_at": nil,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return resumeSuspendedTasks(tx, stuID)
+	})
+}
+
+// resumeSuspendedTasks 密码更新后恢复因密码失效而暂停的任务
+func resumeSuspendedTasks(tx *gorm.DB, stuID string) error {
+	result := tx.Model(&database.Task{}).
+		Where("stu_id = ? AND paused_reason = ?", stuID, database.TaskPausedCredentials).
+		Updates(map[string]interface{}{
+			"paused_reason": "",
+			"exec_status":   "pending",
+			"retry_count":   0,
+			"last_error":    "",
+		})
+	if result.Error != nil {
+		return fmt.Errorf("恢复暂停的任务失败: %v", result.Error)
+	}
+	log.Printf("▶️ 学号 %s 密码已更新，恢复 %d 个暂停的签到任务", stuID, result.RowsAffected)
+	return nil
+}
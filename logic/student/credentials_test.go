@@ -0,0 +1,156 @@
+// logic/student/credentials_test.go
+package student
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/external/platform"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errRejected = fmt.Errorf("登录失败: %w", platform.ErrLoginRejected)
+
+// setupCredentialTest 创建学号 X（绑定用户 1）及一个签到任务，替换通知邮件发送并返回发送记录
+func setupCredentialTest(t *testing.T) (*database.Student, func() []string) {
+	t.Helper()
+	setupTestDB(t)
+	config.CredentialFailThreshold = 3
+
+	var mu sync.Mutex
+	var sent []string
+	origSend := sendCredentialsInvalidEmail
+	sendCredentialsInvalidEmail = func(to, username, stuID, stuName string, paused int64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, to)
+		return nil
+	}
+	t.Cleanup(func() { sendCredentialsInvalidEmail = origSend })
+
+	database.DB.Create(&database.User{ID: 1, Username: "alice", Email: "alice@example.com", Password: "x"})
+	database.DB.Create(&database.UserStudent{UserID: 1, StuID: "X", Name: "张三"})
+	stu := database.Student{StuID: "X", Name: "张三", LastLogin: time.Now()}
+	database.DB.Create(&stu)
+	database.DB.Create(&database.Task{UserID: 1, StuID: "X", ActivityID: "a1", SignTime: "20:30", Enabled: true, ExecStatus: "pending"})
+
+	// 通知在 goroutine 中发送，等待片刻后再读取
+	return &stu, func() []string {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+func TestRecordCredentialResult(t *testing.T) {
+	cases := []struct {
+		name         string
+		results      []error
+		wantFailures int
+		wantInvalid  bool
+	}{
+		{"未达阈值", []error{errRejected, errRejected}, 2, false},
+		{"达到阈值", []error{errRejected, errRejected, errRejected}, 3, true},
+		{"非密码错误不计数", []error{errRejected, errors.New("验证码识别失败"), errors.New("网络超时"), errRejected}, 2, false},
+		{"登录成功清零", []error{errRejected, errRejected, nil, errRejected}, 1, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stu, sent := setupCredentialTest(t)
+			for _, err := range tc.results {
+				RecordCredentialResult(stu, err)
+			}
+			if notified := len(sent()) > 0; notified != tc.wantInvalid {
+				t.Errorf("期望发送通知为 %v，实际 %v", tc.wantInvalid, notified)
+			}
+
+			var saved database.Student
+			database.DB.First(&saved, "stu_id = ?", "X")
+			if saved.CredentialFailures != tc.wantFailures {
+				t.Errorf("期望连续失败 %d 次，实际 %d", tc.wantFailures, saved.CredentialFailures)
+			}
+			if saved.CredentialsInvalid != tc.wantInvalid {
+				t.Errorf("期望失效标记为 %v，实际 %v", tc.wantInvalid, saved.CredentialsInvalid)
+			}
+
+			var task database.Task
+			database.DB.First(&task, "stu_id = ?", "X")
+			if paused := task.PausedReason == database.TaskPausedCredentials; paused != tc.wantInvalid {
+				t.Errorf("期望任务暂停为 %v，实际暂停原因 %q", tc.wantInvalid, task.PausedReason)
+			}
+		})
+	}
+}
+
+// 超过阈值后继续被拒绝，只暂停和通知一次
+func TestSuspendStudentOnlyOnce(t *testing.T) {
+	stu, sent := setupCredentialTest(t)
+	for i := 0; i < 5; i++ {
+		RecordCredentialResult(stu, errRejected)
+	}
+
+	var count int64
+	database.DB.Model(&database.StudentAuditLog{}).
+		Where("stu_id = ? AND action = ?", "X", database.StudentAuditCredentialsInvalid).Count(&count)
+	if count != 1 {
+		t.Errorf("期望记录 1 次失效审计，实际 %d", count)
+	}
+	if got := sent(); len(got) != 1 || got[0] != "alice@example.com" {
+		t.Errorf("期望只通知绑定用户一次，实际 %v", got)
+	}
+}
+
+// 密码失效期间新建的任务直接处于暂停状态
+func TestSaveTaskPausedWhileCredentialsInvalid(t *testing.T) {
+	stu, sent := setupCredentialTest(t)
+	for i := 0; i < config.CredentialFailThreshold; i++ {
+		RecordCredentialResult(stu, errRejected)
+	}
+	sent()
+
+	task := database.Task{UserID: 1, StuID: "X", ActivityID: "a2", SignTime: "21:00"}
+	if err := SaveTask(&task); err != nil {
+		t.Fatalf("保存任务失败: %v", err)
+	}
+	var saved database.Task
+	database.DB.First(&saved, task.ID)
+	if saved.PausedReason != database.TaskPausedCredentials {
+		t.Errorf("期望新任务处于暂停状态，实际暂停原因 %q", saved.PausedReason)
+	}
+}
+
+// 更新密码后恢复暂停的任务，并清除失效标记
+func TestClearCredentialsInvalidResumesTasks(t *testing.T) {
+	stu, sent := setupCredentialTest(t)
+	for i := 0; i < config.CredentialFailThreshold; i++ {
+		RecordCredentialResult(stu, errRejected)
+	}
+	sent()
+	// 用户自己暂停的任务不受影响
+	other := database.Task{UserID: 1, StuID: "X", ActivityID: "a3", SignTime: "21:00", ExecStatus: "pending", PausedReason: "其他原因"}
+	database.DB.Create(&other)
+
+	if err := clearCredentialsInvalid("X"); err != nil {
+		t.Fatalf("清除失效标记失败: %v", err)
+	}
+
+	var saved database.Student
+	database.DB.First(&saved, "stu_id = ?", "X")
+	if saved.CredentialsInvalid || saved.CredentialFailures != 0 {
+		t.Errorf("期望失效标记和失败次数已清除，实际 invalid=%v failures=%d", saved.CredentialsInvalid, saved.CredentialFailures)
+	}
+
+	var tasks []database.Task
+	database.DB.Where("stu_id = ?", "X").Order("id").Find(&tasks)
+	if tasks[0].PausedReason != "" || tasks[0].ExecStatus != "pending" {
+		t.Errorf("期望任务已恢复，实际暂停原因 %q 状态 %q", tasks[0].PausedReason, tasks[0].ExecStatus)
+	}
+	if tasks[1].PausedReason != "其他原因" {
+		t.Errorf("其他原因暂停的任务被恢复")
+	}
+}
@@ -0,0 +1,80 @@
+// logic/student/health.go
+package student
+
+import (
+	"dormcheck/database"
+	"dormcheck/external/platform"
+	"dormcheck/utils"
+	"errors"
+	"log"
+	"time"
+)
+
+// ProbeSession 请求学生详情页探测已保存的登录态是否有效，并把结果记录到学生记录，返回探测结果；
+// 平台熔断时不做探测，返回空字符串
+func ProbeSession(stu *database.Student) string {
+	if platform.CircuitOpen(stu.School) {
+		return ""
+	}
+	status := probeSessionStatus(stu)
+	if status == "" {
+		return ""
+	}
+
+	now := time.Now()
+	stu.SessionStatus = status
+	stu.SessionCheckedAt = &now
+
+	if err := database.DB.Model(&database.Student{}).Where("stu_id = ?", stu.StuID).Updates(map[string]interface{}{
+		"session_status":     status,
+		"session_checked_at": now,
+	}).Error; err != nil {
+		log.Printf("❌ 保存登录态探测结果失败: 学号=%s, 错误=%v", stu.StuID, err)
+	}
+
+	return status
+}
+
+func probeSessionStatus(stu *database.Student) string {
+	if stu.Cookies == "" {
+		return database.SessionStatusExpired
+	}
+
+	cookies, err := utils.DeserializeCookies(stu.Cookies)
+	if err != nil {
+		return database.SessionStatusExpired
+	}
+
+	p, err := platform.For(stu.School, stu.StuID)
+	if err != nil {
+		return database.SessionStatusError
+	}
+
+	if _, err := p.FetchProfile(cookies); err != nil {
+		if errors.Is(err, platform.ErrSessionExpired) {
+			return database.SessionStatusExpired
+		}
+		if errors.Is(err, platform.ErrCircuitOpen) {
+			return ""
+		}
+		log.Printf("⚠️ 登录态探测请求失败: 学号=%s, 错误=%v", stu.StuID, err)
+		return database.SessionStatusError
+	}
+
+	return database.SessionStatusOK
+}
+
+// RecordRefreshResult 记录一次 cookies 刷新的结果；刷新成功说明登录态有效（不落库，由调用方保存）
+func RecordRefreshResult(stu *database.Student, refreshErr error) {
+	now := time.Now()
+	stu.LastRefreshAt = &now
+	stu.LastRefreshOK = refreshErr == nil
+	stu.LastRefreshError = ""
+	if refreshErr != nil {
+		stu.LastRefreshError = refreshErr.Error()
+		return
+	}
+
+	stu.SessionStatus = database.SessionStatusOK
+	stu.SessionCheckedAt = &now
+}
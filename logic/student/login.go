@@ -2,132 +2,214 @@
 package student
 
 import (
+	"dormcheck/captcha"
 	"dormcheck/database"
-	"dormcheck/external/schoollogin"
-	"dormcheck/utils"
+	"dormcheck/external/platform"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
-	"time"
 )
 
-// LoginAndBindStudent 尝试登录微学工平台，并保存学生信息 + 用户绑定 + 姓名
-func LoginAndBindStudent(userID int, stuID, plainPassword string) error {
+// LoginAndBindStudent 尝试登录学校平台，并保存学生信息 + 用户绑定 + 姓名；school 为空时使用默认学校。
+// manual 为 true 或自动识别验证码多次失败时，不直接报错，而是返回需要用户手动输入验证码的绑定会话
+func LoginAndBindStudent(userID int, school, stuID, plainPassword string, manual bool) (*BindChallenge, error) {
 	var lastErr error
-	db := database.DB
 
-	// 🧠 新增：判断绑定数量限制
-	var user database.User
-	if err := db.First(&user, userID).Error; err != nil {
-		return fmt.Errorf("用户不存在")
+	if school == "" {
+		school = platform.DefaultSchool
+	}
+	p, err := platform.For(school, stuID)
+	if err != nil {
+		return nil, err
 	}
 
-	var currentCount int64
-	if err := db.Model(&database.UserStudent{}).Where("user_id = ?", userID).Count(&currentCount).Error; err != nil {
-		return fmt.Errorf("查询已绑定学生失败: %v", err)
+	if err := checkBindLimit(userID); err != nil {
+		return nil, err
 	}
 
-	switch user.Role {
-	case 1: // 普通用户
-		if currentCount >= 2 {
-			return fmt.Errorf("普通用户最多只能绑定 2 名学生")
-		}
-	case 2: // 赞助用户
-		if currentCount >= 12 {
-			return fmt.Errorf("赞助用户最多只能绑定 12 名学生")
-		}
-	case 0:
-		// 管理员无限制
-	default:
-		return fmt.Errorf("未知用户角色")
+	// 学号属于其他用户时只提交绑定申请，不登录平台、不覆盖凭据
+	if err := checkOwnership(userID, stuID, plainPassword); err != nil {
+		return nil, err
+	}
+
+	if manual {
+		return startBindSession(p, userID, school, stuID, plainPassword)
 	}
 
 	for i := 1; i <= 3; i++ {
 		log.Printf("🔁 正在进行第 %d 次登录尝试...\n", i)
 
-		base64Img, preLoginCookies, err := schoollogin.GetValidateCodeBase64()
+		base64Img, preLoginCookies, err := p.GetCaptcha()
 		if err != nil {
-			return fmt.Errorf("获取验证码失败: %v", err)
+			return nil, fmt.Errorf("获取验证码失败: %w", err)
 		}
 
-		valCode, err := utils.RecognizeCaptcha(base64Img)
+		valCode, solverName, err := captcha.Solve(base64Img)
+		if errors.Is(err, captcha.ErrLowConfidence) {
+			log.Printf("⚠️ %v，换一张验证码", err)
+			lastErr = err
+			continue
+		}
 		if err != nil {
-			return fmt.Errorf("验证码识别失败: %v", err)
+			log.Printf("⚠️ 验证码自动识别失败，转为手动输入: %v", err)
+			return startBindSession(p, userID, school, stuID, plainPassword)
 		}
-		log.Println("🤖 AI识别验证码为：", valCode)
+		log.Printf("🤖 验证码识别结果（%s）：%s", solverName, valCode)
 
-		loginResult, err := schoollogin.Login(stuID, plainPassword, valCode, preLoginCookies)
+		loginCookies, err := p.Login(stuID, plainPassword, valCode, preLoginCookies)
+		captcha.RecordSample(base64Img, valCode, solverName, database.CaptchaSourceBind, captchaAccepted(err))
 		if err != nil {
 			fmt.Println("⚠️ 登录失败:", err)
 
-			if strings.Contains(err.Error(), "验证码") || strings.Contains(err.Error(), "ValCode") {
+			if errors.Is(err, platform.ErrCaptchaWrong) {
 				lastErr = err
 				continue
 			}
-			return fmt.Errorf("登录失败: %v", err)
+			return nil, fmt.Errorf("登录失败: %w", err)
 		}
 
-		studentName, err := schoollogin.GetStudentNameFromDetail(loginResult.Cookies)
-		if err != nil {
-			log.Println("⚠️ 获取学生姓名失败，将使用空值。", err)
-			studentName = ""
-		} else {
-			log.Printf("🎓 获取到学生姓名：%s\n", studentName)
-		}
+		return nil, completeBind(p, userID, school, stuID, plainPassword, loginCookies)
+	}
 
-		err = database.SaveStudentOrUpdate(&database.Student{
-			StuID:     stuID,
-			Password:  plainPassword,
-			Cookies:   utils.SerializeCookies(loginResult.Cookies),
-			LastLogin: time.Now(),
-			Name:      studentName,
-		})
-		if err != nil {
-			return fmt.Errorf("保存学生信息失败: %v", err)
-		}
+	log.Printf("⚠️ 多次自动识别验证码均失败，转为手动输入: %v", lastErr)
+	return startBindSession(p, userID, school, stuID, plainPassword)
+}
 
-		err = database.BindUserAndStudent(userID, stuID, studentName)
-		if err != nil {
-			return fmt.Errorf("用户与学号绑定失败: %v", err)
+// checkBindLimit 按用户角色检查绑定数量限制
+func checkBindLimit(userID int) error {
+	db := database.DB
+
+	var user database.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在")
+	}
+
+	var currentCount int64
+	if err := db.Model(&database.UserStudent{}).Where("user_id = ?", userID).Count(&currentCount).Error; err != nil {
+		return fmt.Errorf("查询已绑定学生失败: %v", err)
+	}
+
+	if !user.Role.Valid() {
+		return fmt.Errorf("未知用户角色")
+	}
+	if limit := user.Role.BindLimit(); limit >= 0 && currentCount >= int64(limit) {
+		return fmt.Errorf("%s最多只能绑定 %d 名学生", user.Role, limit)
+	}
+	return nil
+}
+
+// completeBind 登录成功后获取姓名、保存学生信息并建立绑定；学号尚无所有者时当前用户成为所有者
+func completeBind(p platform.Platform, userID int, school, stuID, plainPassword string, loginCookies []*http.Cookie) error {
+	var studentName string
+	profile, err := p.FetchProfile(loginCookies)
+	if err != nil {
+		log.Println("⚠️ 获取学生姓名失败，将使用空值。", err)
+	} else {
+		studentName = profile.Name
+		log.Printf("🎓 获取到学生姓名：%s\n", studentName)
+	}
+
+	// 手动输入验证码的绑定可能在发起后很久才完成，期间可能已绑定了其他学号，保存前重新检查数量上限
+	bound, err := UserBoundToStudent(userID, stuID)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		if err := checkBindLimit(userID); err != nil {
+			return err
 		}
+	}
 
-		return nil
+	stu := &database.Student{
+		StuID:       stuID,
+		School:      school,
+		Password:    plainPassword,
+		Name:        studentName,
+		OwnerUserID: &userID,
 	}
+	ApplySession(stu, loginCookies)
+	RecordRefreshResult(stu, nil)
 
-	return fmt.Errorf("多次尝试登录均失败: %v", lastErr)
+	err = database.SaveStudentOrUpdate(stu)
+	if errors.Is(err, database.ErrNotStudentOwner) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("保存学生信息失败: %v", err)
+	}
+	InvalidateActivityCache(stuID)
+
+	if err := clearCredentialsInvalid(stuID); err != nil {
+		log.Printf("⚠️ 恢复学号 %s 暂停的任务失败: %v", stuID, err)
+	}
+
+	err = database.BindUserAndStudent(userID, stuID, studentName)
+	if err != nil {
+		return fmt.Errorf("用户与学号绑定失败: %v", err)
+	}
+	database.RecordStudentAudit(database.DB, stuID, userID, database.StudentAuditBind, "登录验证通过，已更新登录凭据")
+
+	return nil
 }
 
-// LoginWithoutBind 仅用于登录获取 cookies，不进行绑定
-func LoginWithoutBind(stuID, plainPassword string) ([]*http.Cookie, error) {
+// LoginWithoutBind 仅用于登录获取 cookies，不进行绑定；source 标记验证码样本的来源
+func LoginWithoutBind(school, stuID, plainPassword, source string) ([]*http.Cookie, error) {
 	var lastErr error
 
+	p, err := platform.For(school, stuID)
+	if err != nil {
+		return nil, err
+	}
+
 	for i := 1; i <= 3; i++ {
 		log.Printf("🔁 第 %d 次尝试登录学号 %s...\n", i, stuID)
 
 		// 获取验证码图像
-		base64Img, preCookies, err := schoollogin.GetValidateCodeBase64()
+		base64Img, preCookies, err := p.GetCaptcha()
 		if err != nil {
-			return nil, fmt.Errorf("获取验证码失败: %v", err)
+			return nil, fmt.Errorf("获取验证码失败: %w", err)
 		}
 
-		valCode, err := utils.RecognizeCaptcha(base64Img)
+		valCode, solverName, err := captcha.Solve(base64Img)
+		if errors.Is(err, captcha.ErrLowConfidence) {
+			log.Printf("⚠️ %v，换一张验证码", err)
+			lastErr = err
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("验证码识别失败: %v", err)
 		}
+		log.Printf("🤖 验证码识别结果（%s）：%s", solverName, valCode)
 
 		// 登录请求
-		loginResult, err := schoollogin.Login(stuID, plainPassword, valCode, preCookies)
+		cookies, err := p.Login(stuID, plainPassword, valCode, preCookies)
+		captcha.RecordSample(base64Img, valCode, solverName, source, captchaAccepted(err))
 		if err != nil {
-			if strings.Contains(err.Error(), "验证码") {
+			if errors.Is(err, platform.ErrCaptchaWrong) {
 				lastErr = err
 				continue
 			}
-			return nil, fmt.Errorf("登录失败: %v", err)
+			return nil, fmt.Errorf("登录失败: %w", err)
 		}
 
-		return loginResult.Cookies, nil
+		return cookies, nil
 	}
 
 	return nil, fmt.Errorf("多次登录失败: %v", lastErr)
 }
+
+// captchaAccepted 根据登录结果判断平台是否接受了验证码；无法判断时返回 nil
+func captchaAccepted(loginErr error) *bool {
+	accepted := true
+	switch {
+	case loginErr == nil, errors.Is(loginErr, platform.ErrLoginRejected):
+		// 登录成功，或验证码通过但账号密码被拒
+		return &accepted
+	case errors.Is(loginErr, platform.ErrCaptchaWrong):
+		accepted = false
+		return &accepted
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+// logic/student/ownership.go
+package student
+
+import (
+	"crypto/subtle"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrBindPendingApproval 学号已有其他所有者，已提交绑定申请，等待所有者批准
+var ErrBindPendingApproval = errors.New("该学号已由其他用户绑定，已向其发送绑定申请，批准后即可使用")
+
+// BindRequestInfo 返回给所有者的待处理绑定申请
+type BindRequestInfo struct {
+	ID            uint      `json:"id"`
+	StuID         string    `json:"stu_id"`
+	RequesterID   int       `json:"requester_id"`
+	RequesterName string    `json:"requester_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// checkOwnership 绑定前检查学号所有者：无所有者或本人是所有者时返回 nil，可正常登录绑定；
+// 学号属于其他用户时先按用户和学号限制申请次数，再创建绑定申请并返回 ErrBindPendingApproval。
+// 无论密码是否与已保存的一致都返回同样的结果，避免被当作密码校验接口
+func checkOwnership(userID int, stuID, plainPassword string) error {
+	var stu database.Student
+	err := database.DB.First(&stu, "stu_id = ?", stuID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stu.OwnerUserID == nil || *stu.OwnerUserID == userID {
+		return nil
+	}
+
+	// 已绑定的非所有者重新绑定相当于更新凭据，不允许
+	bound, err := UserBoundToStudent(userID, stuID)
+	if err != nil {
+		return err
+	}
+	if bound {
+		return database.ErrNotStudentOwner
+	}
+
+	if err := takeBindAttempt(userID, stuID); err != nil {
+		return err
+	}
+
+	// 不向平台登录，用已保存的密码证明申请人确实知道该学号的密码
+	matched := subtle.ConstantTimeCompare([]byte(stu.Password), []byte(plainPassword)) == 1
+	return requestBinding(userID, *stu.OwnerUserID, stuID, matched)
+}
+
+// requestBinding 记录绑定申请；只有密码一致的申请才通知所有者，已有待处理的同类申请时不重复创建
+func requestBinding(userID, ownerID int, stuID string, matched bool) error {
+	db := database.DB
+
+	if matched {
+		var count int64
+		if err := db.Model(&database.StudentBindRequest{}).
+			Where("stu_id = ? AND requester_id = ? AND status = ? AND password_matched = ?",
+				stuID, userID, database.BindRequestPending, true).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrBindPendingApproval
+		}
+	}
+
+	req := database.StudentBindRequest{
+		StuID:           stuID,
+		RequesterID:     userID,
+		OwnerUserID:     ownerID,
+		Status:          database.BindRequestPending,
+		PasswordMatched: matched,
+	}
+	// 密码不一致的申请直接记为拒绝，仅用于审计
+	if !matched {
+		req.Status = database.BindRequestRejected
+	}
+	if err := db.Create(&req).Error; err != nil {
+		return fmt.Errorf("创建绑定申请失败: %v", err)
+	}
+	detail := fmt.Sprintf("申请 #%d", req.ID)
+	if !matched {
+		detail += "，密码与保存的不一致"
+	}
+	database.RecordStudentAudit(db, stuID, userID, database.StudentAuditBindRequest, detail)
+	if !matched {
+		return ErrBindPendingApproval
+	}
+
+	var owner, requester database.User
+	if db.First(&owner, ownerID).Error == nil && db.First(&requester, userID).Error == nil {
+		go func() {
+			if err := utils.SendBindRequestEmail(owner.Email, owner.Username, requester.Username, stuID); err != nil {
+				log.Printf("❌ 发送绑定申请邮件失败: %v", err)
+			}
+		}()
+	}
+
+	return ErrBindPendingApproval
+}
+
+// ListBindRequests 查询所有者名下学号的待处理绑定申请
+func ListBindRequests(ownerID int) ([]BindRequestInfo, error) {
+	var reqs []database.StudentBindRequest
+	if err := database.DB.
+		Where("owner_user_id = ? AND status = ? AND password_matched = ?", ownerID, database.BindRequestPending, true).
+		Order("id").Find(&reqs).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]BindRequestInfo, 0, len(reqs))
+	for _, r := range reqs {
+		info := BindRequestInfo{ID: r.ID, StuID: r.StuID, RequesterID: r.RequesterID, CreatedAt: r.CreatedAt}
+		var requester database.User
+		if database.DB.First(&requester, r.RequesterID).Error == nil {
+			info.RequesterName = requester.Username
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// DecideBindRequest 所有者批准或拒绝绑定申请；批准后为申请人建立绑定
+func DecideBindRequest(ownerID int, requestID uint, approve bool) error {
+	var req database.StudentBindRequest
+	var stuName string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&req, requestID).Error; err != nil || !req.PasswordMatched {
+			return errors.New("绑定申请不存在")
+		}
+		if req.Status != database.BindRequestPending {
+			return errors.New("该申请已处理")
+		}
+
+		// 以学号当前的所有者为准，所有权转移后旧所有者不能再处理
+		var stu database.Student
+		if err := tx.First(&stu, "stu_id = ?", req.StuID).Error; err != nil {
+			return errors.New("学号不存在")
+		}
+		if stu.OwnerUserID == nil || *stu.OwnerUserID != ownerID {
+			return errors.New("只有该学号的所有者才能处理绑定申请")
+		}
+		stuName = stu.Name
+
+		now := time.Now()
+		req.DecidedAt = &now
+		req.Status = database.BindRequestRejected
+		action := database.StudentAuditBindReject
+		if approve {
+			if err := checkBindLimit(req.RequesterID); err != nil {
+				return fmt.Errorf("申请人无法再绑定: %v", err)
+			}
+			binding := database.UserStudent{UserID: req.RequesterID, StuID: req.StuID, Name: stu.Name}
+			if err := tx.Where("user_id = ? AND stu_id = ?", req.RequesterID, req.StuID).
+				FirstOrCreate(&binding).Error; err != nil {
+				return fmt.Errorf("建立绑定失败: %v", err)
+			}
+			req.Status = database.BindRequestApproved
+			action = database.StudentAuditBindApprove
+		}
+		if err := tx.Save(&req).Error; err != nil {
+			return err
+		}
+
+		database.RecordStudentAudit(tx, req.StuID, ownerID, action, fmt.Sprintf("申请 #%d，申请人 %d", req.ID, req.RequesterID))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🔑 学号 %s（%s）的绑定申请 #%d 已处理：%s", req.StuID, stuName, req.ID, req.Status)
+
+	var requester database.User
+	if database.DB.First(&requester, req.RequesterID).Error == nil {
+		go func() {
+			if err := utils.SendBindDecisionEmail(requester.Email, requester.Username, req.StuID, approve); err != nil {
+				log.Printf("❌ 发送绑定申请结果邮件失败: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// ListStudentAudit 查询学号的审计日志（最近 100 条），只有已绑定的用户能查看
+func ListStudentAudit(userID int, stuID string) ([]database.StudentAuditLog, error) {
+	if err := EnsureStudentBinding(userID, stuID); err != nil {
+		return nil, err
+	}
+
+	var logs []database.StudentAuditLog
+	err := database.DB.Where("stu_id = ?", stuID).Order("id DESC").Limit(100).Find(&logs).Error
+	return logs, err
+}
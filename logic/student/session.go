@@ -0,0 +1,20 @@
+// logic/student/session.go
+package student
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"net/http"
+	"time"
+)
+
+// ApplySession 将新登录得到的 cookies 写入学生记录（不落库），同时更新登录时间和预计失效时间
+func ApplySession(stu *database.Student, cookies []*http.Cookie) {
+	stu.Cookies = utils.SerializeCookies(cookies)
+	stu.LastLogin = time.Now()
+	stu.SessionExpiresAt = nil
+	if expiry, ok := utils.SessionExpiry(stu.Cookies, config.SessionMaxAge); ok {
+		stu.SessionExpiresAt = &expiry
+	}
+}
@@ -2,14 +2,11 @@ package student
 
 import (
 	"dormcheck/database"
+	"dormcheck/external/platform"
 	"dormcheck/utils"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
-	"strings"
 	"time"
 )
 
@@ -49,6 +46,11 @@ func ExecuteSignTask(task *database.Task) error {
 		return nil
 	}
 
+	// 任务创建者必须仍绑定该学号，否则不能使用该学生的登录态
+	if err := EnsureStudentBinding(task.UserID, task.StuID); err != nil {
+		return updateAndReturn("failed", err.Error())
+	}
+
 	// 查询学生信息
 	var stu database.Student
 	if err := database.DB.First(&stu, "stu_id = ?", task.StuID).Error; err != nil {
@@ -64,54 +66,30 @@ func ExecuteSignTask(task *database.Task) error {
 		return updateAndReturn("failed", fmt.Sprintf("cookie 解析失败: %v", err))
 	}
 
-	// 构造请求
-	form := url.Values{
-		"ActivityId":     {task.ActivityID},
-		"ReasonText":     {""},
-		"guidValue":      {""},
-		"address":        {task.Address},
-		"longitudeGaoDe": {fmt.Sprintf("%.6f", task.Longitude)},
-		"latitudeGaoDe":  {fmt.Sprintf("%.5f", task.Latitude)},
-		"RType":          {"1"},
-	}
-	req, err := http.NewRequest("POST", "http://plat.swmu.edu.cn/studentwork/PunchMStudent/SubmitSignin", strings.NewReader(form.Encode()))
+	p, err := platform.For(stu.School, stu.StuID)
 	if err != nil {
-		return updateAndReturn("failed", fmt.Sprintf("请求构造失败: %v", err))
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-
-	// 添加 Cookie
-	var cookieStrs []string
-	for _, ck := range cookies {
-		cookieStrs = append(cookieStrs, ck.Name+"="+ck.Value)
+		return updateAndReturn("failed", err.Error())
 	}
-	req.Header.Set("Cookie", strings.Join(cookieStrs, "; "))
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 提交签到
+	result, err := p.SubmitSignIn(cookies, platform.SignInRequest{
+		ActivityID: task.ActivityID,
+		Address:    task.Address,
+		Longitude:  task.Longitude,
+		Latitude:   task.Latitude,
+	})
 	if err != nil {
-		return updateAndReturn("failed", fmt.Sprintf("请求发送失败: %v", err))
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	// 解析响应
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return updateAndReturn("failed", fmt.Sprintf("响应解析失败: %v", err))
+		// 平台熔断中：推迟任务，不计入重试次数也不发送失败通知
+		if errors.Is(err, platform.ErrCircuitOpen) {
+			return err
+		}
+		return updateAndReturn("failed", err.Error())
 	}
 
-	// 提取结果
-	isOK, _ := result["isok"].(bool)
-	msg, _ := result["msg"].(string)
-
 	// 判断状态
-	if isOK || msg == "该活动已经签到成功" {
+	if result.Success {
 		return updateAndReturn("success", "")
 	} else {
-		return updateAndReturn("failed", msg)
+		return updateAndReturn("failed", result.Message)
 	}
 }
@@ -0,0 +1,65 @@
+// logic/student/submit_test.go
+package student
+
+import (
+	"dormcheck/database"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用内存 SQLite 替换全局数据库
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // 内存库每个连接各自独立
+	if err := db.AutoMigrate(&database.User{}, &database.UserStudent{}, &database.Student{},
+		&database.Task{}, &database.StudentAuditLog{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+// 任务创建者解绑学号后，任务不能再使用该学号的登录态执行
+func TestExecuteSignTaskRefusesUnboundCreator(t *testing.T) {
+	setupTestDB(t)
+
+	task := database.Task{UserID: 2, StuID: "X", ActivityID: "a1", SignTime: "20:30", Enabled: true, ExecStatus: "pending"}
+	database.DB.Create(&task)
+	database.DB.Create(&database.UserStudent{UserID: 1, StuID: "X", Name: "张三"})
+
+	err := ExecuteSignTask(&task)
+	if err == nil {
+		t.Fatal("期望执行被拒绝")
+	}
+
+	var saved database.Task
+	database.DB.First(&saved, task.ID)
+	if saved.ExecStatus != "failed" {
+		t.Errorf("期望任务状态为 failed，实际 %q", saved.ExecStatus)
+	}
+	if saved.LastError != ErrStudentNotBound.Error() {
+		t.Errorf("期望错误为 %q，实际 %q", ErrStudentNotBound, saved.LastError)
+	}
+}
+
+func TestEnsureStudentBinding(t *testing.T) {
+	setupTestDB(t)
+	database.DB.Create(&database.UserStudent{UserID: 1, StuID: "X", Name: "张三"})
+
+	if err := EnsureStudentBinding(1, "X"); err != nil {
+		t.Errorf("已绑定用户被拒绝: %v", err)
+	}
+	if err := EnsureStudentBinding(2, "X"); !errors.Is(err, ErrStudentNotBound) {
+		t.Errorf("期望 ErrStudentNotBound，实际 %v", err)
+	}
+}
@@ -12,6 +12,10 @@ import (
 // SaveTask 尝试保存签到任务，重复可更新
 // SaveTask 尝试保存签到任务，重复可更新
 func SaveTask(task *database.Task) error {
+	if err := EnsureStudentBinding(task.UserID, task.StuID); err != nil {
+		return err
+	}
+
 	// 查找是否存在同 user_id、stu_id、activity_id 的任务
 	var existing database.Task
 	err := database.DB.
@@ -26,6 +30,12 @@ func SaveTask(task *database.Task) error {
 			task.RetryCount = 0
 			task.ExecutedAt = time.Time{}
 
+			// 学号密码已失效时新任务同样暂停，更新密码后一起恢复
+			var stu database.Student
+			if err := database.DB.First(&stu, "stu_id = ?", task.StuID).Error; err == nil && stu.CredentialsInvalid {
+				task.PausedReason = database.TaskPausedCredentials
+			}
+
 			return database.DB.Create(task).Error
 		}
 		// 查询失败
@@ -0,0 +1,164 @@
+// logic/user/access_token.go
+package user
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccessTokenPrefix 个人访问令牌的固定前缀，用于和 JWT 区分
+const AccessTokenPrefix = "dcp_"
+
+// AccessTokenInfo 个人访问令牌列表项，不含令牌本身
+type AccessTokenInfo struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAccessToken 创建个人访问令牌，明文令牌只在创建时返回一次
+func CreateAccessToken(userID int, name string, scopes []string, ttl time.Duration) (string, *AccessTokenInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 50 {
+		return "", nil, errors.New("令牌名称不能为空且不能超过 50 个字符")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("请至少选择一个权限范围")
+	}
+	for _, s := range scopes {
+		if !database.TokenScope(s).Valid() {
+			return "", nil, fmt.Errorf("未知的权限范围: %s", s)
+		}
+	}
+	if ttl <= 0 || ttl > config.AccessTokenMaxTTL {
+		return "", nil, fmt.Errorf("有效期必须大于 0 且不超过 %d 天", int(config.AccessTokenMaxTTL.Hours()/24))
+	}
+
+	var count int64
+	if err := database.DB.Model(&database.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&count).Error; err != nil {
+		return "", nil, err
+	}
+	if count >= int64(config.AccessTokenMaxCount) {
+		return "", nil, fmt.Errorf("最多只能持有 %d 个有效令牌，请先吊销不用的令牌", config.AccessTokenMaxCount)
+	}
+
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := AccessTokenPrefix + secret
+
+	record := database.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(AccessTokenPrefix)+6],
+		TokenHash: utils.HashToken(token),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := database.DB.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+
+	info := accessTokenInfo(record)
+	return token, &info, nil
+}
+
+// ListAccessTokens 查询用户未吊销的个人访问令牌（含已过期的，便于用户清理）
+func ListAccessTokens(userID int) ([]AccessTokenInfo, error) {
+	var records []database.PersonalAccessToken
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("id DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]AccessTokenInfo, 0, len(records))
+	for _, r := range records {
+		result = append(result, accessTokenInfo(r))
+	}
+	return result, nil
+}
+
+// RevokeAccessToken 吊销用户自己的个人访问令牌
+func RevokeAccessToken(userID int, tokenID uint) error {
+	result := database.DB.Model(&database.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌不存在或已吊销")
+	}
+	return nil
+}
+
+// revokeAllAccessTokens 吊销用户的全部个人访问令牌
+func revokeAllAccessTokens(db *gorm.DB, userID int) error {
+	return db.Model(&database.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ParseAccessToken 校验个人访问令牌并返回其记录，同时记录最近使用时间和 IP
+func ParseAccessToken(token, ip string) (*database.PersonalAccessToken, error) {
+	var record database.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&record).Error; err != nil {
+		return nil, errors.New("令牌无效")
+	}
+	if record.RevokedAt != nil {
+		return nil, errors.New("令牌已吊销")
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, errors.New("令牌已过期")
+	}
+
+	// 最近使用时间每分钟最多更新一次，避免每个请求都写库
+	if record.LastUsedAt == nil || time.Since(*record.LastUsedAt) > time.Minute || record.LastUsedIP != ip {
+		now := time.Now()
+		database.DB.Model(&record).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+	return &record, nil
+}
+
+// AccessTokenScopes 解析令牌记录中的权限范围
+func AccessTokenScopes(record *database.PersonalAccessToken) []database.TokenScope {
+	var scopes []database.TokenScope
+	for _, s := range strings.Split(record.Scopes, ",") {
+		if s != "" {
+			scopes = append(scopes, database.TokenScope(s))
+		}
+	}
+	return scopes
+}
+
+func accessTokenInfo(r database.PersonalAccessToken) AccessTokenInfo {
+	scopes := make([]string, 0)
+	for _, s := range AccessTokenScopes(&r) {
+		scopes = append(scopes, string(s))
+	}
+	return AccessTokenInfo{
+		ID:         r.ID,
+		Name:       r.Name,
+		Prefix:     r.Prefix,
+		Scopes:     scopes,
+		ExpiresAt:  r.ExpiresAt,
+		LastUsedAt: r.LastUsedAt,
+		LastUsedIP: r.LastUsedIP,
+		CreatedAt:  r.CreatedAt,
+	}
+}
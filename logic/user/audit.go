@@ -0,0 +1,79 @@
+// logic/user/audit.go
+package user
+
+import (
+	"dormcheck/database"
+	"strconv"
+	"time"
+)
+
+// recordLoginAudit 记录一次登录尝试；userID 为 0 表示账号不存在。失败时操作者未知，只记录目标账号
+func recordLoginAudit(action string, userID int, device DeviceInfo, result *LoginResult, err error) {
+	e := database.AuditEvent{
+		IP:         device.IP,
+		UserAgent:  device.UserAgent,
+		Action:     action,
+		TargetType: database.AuditTargetUser,
+		Result:     database.AuditResultSuccess,
+	}
+	if userID != 0 {
+		e.TargetID = strconv.Itoa(userID)
+	}
+	if err == nil {
+		e.ActorID = userID
+	}
+	switch {
+	case err != nil:
+		e.Result = database.AuditResultFailure
+		e.Detail = err.Error()
+	case result != nil && result.TwoFactorRequired:
+		e.Detail = "密码正确，等待两步验证"
+	}
+	database.RecordAuditEvent(e)
+}
+
+// SecurityEvent 返回给用户本人的安全记录，只包含用户应看到的字段
+type SecurityEvent struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `json:"action"`
+	Result    string    `json:"result"`
+	Detail    string    `json:"detail,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// RecentSecurityActivity 查询用户最近的安全事件：本人的操作，以及针对本人账号的操作（如失败的登录、管理员修改角色）。
+// 他人（如管理员）的操作不返回其 IP 和 User-Agent；针对本账号的失败登录保留，便于用户发现异常登录
+func RecentSecurityActivity(userID, limit int) ([]SecurityEvent, error) {
+	var events []database.AuditEvent
+	if err := database.DB.
+		Where("actor_id = ? OR (target_type = ? AND target_id = ?)", userID, database.AuditTargetUser, strconv.Itoa(userID)).
+		Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]SecurityEvent, 0, len(events))
+	for _, e := range events {
+		se := SecurityEvent{
+			ID:        e.ID,
+			CreatedAt: e.CreatedAt,
+			Action:    e.Action,
+			Result:    e.Result,
+			Detail:    e.Detail,
+		}
+		if e.ActorID == userID || isFailedLoginAgainst(e, userID) {
+			se.IP = e.IP
+			se.UserAgent = e.UserAgent
+		}
+		result = append(result, se)
+	}
+	return result, nil
+}
+
+// isFailedLoginAgainst 判断事件是否为针对该账号的失败登录
+func isFailedLoginAgainst(e database.AuditEvent, userID int) bool {
+	return (e.Action == database.AuditLogin || e.Action == database.AuditLogin2FA) &&
+		e.Result == database.AuditResultFailure &&
+		e.TargetType == database.AuditTargetUser && e.TargetID == strconv.Itoa(userID)
+}
@@ -35,9 +35,9 @@ func Register(username, email, encodedPassword, code string) error {
 	db := database.DB
 
 	// 校验验证码
-	var evc database.EmailVerificationCode
-	if err := db.Where("email = ? AND code = ? AND purpose = ? AND expires_at > ?", email, code, "register", time.Now()).First(&evc).Error; err != nil {
-		return errors.New("验证码错误或已过期")
+	evc, err := checkVerificationCode(db, email, "register", code)
+	if err != nil {
+		return err
 	}
 
 	// 查重：用户名或邮箱任意一个已存在都返回错误
@@ -57,14 +57,14 @@ func Register(username, email, encodedPassword, code string) error {
 		EmailVerified: true,
 		Password:      hashed,
 		TokenVersion:  1,
-		Role:          1,
+		Role:          database.RoleUser,
 	}
 
 	if err := db.Create(&newUser).Error; err != nil {
 		return err
 	}
 
-	_ = db.Delete(&evc).Error
+	_ = db.Delete(evc).Error
 
 	return nil
 }
@@ -85,63 +85,86 @@ func SendRegisterVerificationCode(email string) error {
 		return errors.New("验证码发送过于频繁，请稍后再试")
 	}
 
-	code, err := utils.GenerateVerificationCode()
-	if err != nil {
-		return err
-	}
-
-	if err := utils.SendVerificationCodeEmail(email, code); err != nil {
-		return err
-	}
-
-	evc := database.EmailVerificationCode{
-		Email:     email,
-		Code:      code,
-		Purpose:   "register",
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-		CreatedAt: time.Now(),
-	}
-
-	return db.Create(&evc).Error
+	return issueVerificationCode(email, "register")
 }
 
-// 登录，返回 token
-func Login(identifier, encodedPassword string) (string, error) {
+// 登录，为当前设备创建会话并返回访问令牌与刷新令牌；启用两步验证的用户返回两步验证挑战。
+// 同一账号、同一 IP 连续失败后需要逐渐延长等待时间，达到阈值后临时锁定（返回 ErrLoginThrottled）
+func Login(identifier, encodedPassword string, device DeviceInfo) (result *LoginResult, err error) {
+	// 找到账号后记为该用户的事件，便于用户在安全记录中看到针对自己账号的失败登录
+	var targetID int
+	defer func() {
+		recordLoginAudit(database.AuditLogin, targetID, device, result, err)
+	}()
+
 	if identifier == "" || encodedPassword == "" {
-		return "", errors.New("用户名/邮箱 和 密码不能为空")
+		return nil, errors.New("用户名/邮箱 和 密码不能为空")
 	}
 
 	password, err := decodePassword(encodedPassword)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// 先计入一次 IP 失败，登录成功后清除
+	if err := takeIPAttempt(device.IP); err != nil {
+		return nil, err
 	}
 
 	var user database.User
 	db := database.DB
 
 	if err := db.Where("username = ? OR email = ?", identifier, identifier).First(&user).Error; err != nil {
-		return "", errors.New("用户名或密码错误")
+		return nil, errors.New("用户名或密码错误")
+	}
+	targetID = user.ID
+
+	if err := checkAccountAllowed(&user); err != nil {
+		return nil, err
 	}
 
 	if !utils.CheckPassword(user.Password, password) {
-		return "", errors.New("用户名或密码错误")
+		recordAccountFailure(&user, device.IP)
+		return nil, errors.New("用户名或密码错误")
 	}
 
-	return utils.GenerateToken(user)
+	// 启用两步验证时，本次计入的 IP 失败和账号的失败次数都保留到两步验证通过后再清除，
+	// 否则拿到密码的人每次重新登录都能清空之前错误验证码累计的次数
+	if user.TOTPEnabled {
+		challenge, err := newLoginChallenge(user.ID, device)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	refundIPAttempt(device.IP)
+	resetAccountFailures(&user)
+
+	tokens, _, err := createSession(user, device)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokens}, nil
 }
 
-// 强制注销所有设备（让所有 token 失效）
+// 强制注销所有设备（让所有 token 失效，并注销全部刷新令牌和个人访问令牌）
 func ForceLogoutAll(userID int) error {
-	result := database.DB.Model(&database.User{}).Where("id = ?", userID).
-		Update("token_version", gorm.Expr("token_version + 1"))
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1"))
 
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return errors.New("用户不存在")
-	}
-	return nil
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户不存在")
+		}
+		if err := revokeAllSessions(tx, userID); err != nil {
+			return err
+		}
+		return revokeAllAccessTokens(tx, userID)
+	})
 }
 
 // 通过ID查询用户
@@ -229,10 +252,9 @@ func ChangeUserEmail(userID int, newEmail, code string) error {
 		return errors.New("新邮箱不能与旧邮箱相同")
 	}
 
-	var evc database.EmailVerificationCode
-	if err := db.Where("email = ? AND code = ? AND purpose = ? AND expires_at > ?", newEmail, code, "change_email", time.Now()).
-		First(&evc).Error; err != nil {
-		return errors.New("验证码错误或已过期")
+	evc, err := checkVerificationCode(db, newEmail, "change_email", code)
+	if err != nil {
+		return err
 	}
 
 	var existing database.User
@@ -247,12 +269,13 @@ func ChangeUserEmail(userID int, newEmail, code string) error {
 		return errors.New("更新邮箱失败")
 	}
 
-	_ = db.Delete(&evc).Error
+	_ = db.Delete(evc).Error
 
 	return nil
 }
 
-func SendChangeEmailVerificationCode(email string) error {
+// 发送修改邮箱验证码（需登录），新邮箱不能是当前邮箱或已被其他用户使用
+func SendChangeEmailVerificationCode(userID int, email string) error {
 	re := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 	if !re.MatchString(email) {
 		return errors.New("邮箱格式不正确")
@@ -260,6 +283,18 @@ func SendChangeEmailVerificationCode(email string) error {
 
 	db := database.DB
 
+	var user database.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+	if user.Email == email {
+		return errors.New("新邮箱不能与旧邮箱相同")
+	}
+	var existing database.User
+	if err := db.Where("email = ?", email).First(&existing).Error; err == nil {
+		return errors.New("该邮箱已被其他用户绑定")
+	}
+
 	var lastCode database.EmailVerificationCode
 	err := db.Where("email = ? AND purpose = ?", email, "change_email").
 		Order("created_at DESC").First(&lastCode).Error
@@ -267,24 +302,7 @@ func SendChangeEmailVerificationCode(email string) error {
 		return errors.New("验证码发送过于频繁，请稍后再试")
 	}
 
-	code, err := utils.GenerateVerificationCode()
-	if err != nil {
-		return err
-	}
-
-	if err := utils.SendVerificationCodeEmail(email, code); err != nil {
-		return err
-	}
-
-	evc := database.EmailVerificationCode{
-		Email:     email,
-		Code:      code,
-		Purpose:   "change_email",
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-		CreatedAt: time.Now(),
-	}
-
-	return db.Create(&evc).Error
+	return issueVerificationCode(email, "change_email")
 }
 
 // 赞助码激活
@@ -295,7 +313,7 @@ func UseSponsorCode(userID int, code string) error {
 	if err := db.First(&user, userID).Error; err != nil {
 		return errors.New("用户不存在")
 	}
-	if user.Role == 2 {
+	if user.Role == database.RoleSponsor {
 		return errors.New("您已是赞助用户，无需重复激活")
 	}
 
@@ -319,7 +337,7 @@ func UseSponsorCode(userID int, code string) error {
 			return err
 		}
 
-		if err := tx.Model(&database.User{}).Where("id = ?", userID).Update("role", 2).Error; err != nil {
+		if err := tx.Model(&database.User{}).Where("id = ?", userID).Update("role", database.RoleSponsor).Error; err != nil {
 			return err
 		}
 
@@ -348,24 +366,7 @@ func SendResetPasswordCode(email string) error {
 		return errors.New("验证码发送过于频繁，请稍后再试")
 	}
 
-	code, err := utils.GenerateVerificationCode()
-	if err != nil {
-		return err
-	}
-
-	if err := utils.SendVerificationCodeEmail(email, code); err != nil {
-		return err
-	}
-
-	evc := database.EmailVerificationCode{
-		Email:     email,
-		Code:      code,
-		Purpose:   "reset",
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-		CreatedAt: time.Now(),
-	}
-
-	return db.Create(&evc).Error
+	return issueVerificationCode(email, "reset")
 }
 
 // 重置密码通过验证码
@@ -376,10 +377,9 @@ func ResetPasswordByCode(email, code, encodedNewPassword string) error {
 
 	db := database.DB
 
-	var evc database.EmailVerificationCode
-	if err := db.Where("email = ? AND code = ? AND purpose = ? AND expires_at > ?", email, code, "reset", time.Now()).
-		First(&evc).Error; err != nil {
-		return errors.New("验证码错误或已过期")
+	evc, err := checkVerificationCode(db, email, "reset", code)
+	if err != nil {
+		return err
 	}
 
 	newPassword, err := decodePassword(encodedNewPassword)
@@ -396,7 +396,7 @@ func ResetPasswordByCode(email, code, encodedNewPassword string) error {
 		return errors.New("密码更新失败")
 	}
 
-	_ = db.Delete(&evc).Error
+	_ = db.Delete(evc).Error
 
 	return nil
 }
@@ -416,3 +416,19 @@ func ResetPasswordAndForceLogout(email, code, encodedNewPassword string) error {
 
 	return nil
 }
+
+// SetUserRole 管理员修改用户角色
+func SetUserRole(userID int, role database.Role) error {
+	if !role.Valid() {
+		return errors.New("无效的角色")
+	}
+
+	result := database.DB.Model(&database.User{}).Where("id = ?", userID).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	return nil
+}
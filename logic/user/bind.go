@@ -3,6 +3,10 @@ package user
 import (
 	"dormcheck/database"
 	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 // 解绑学号前检查是否还有任务，若无任务才解绑
@@ -22,21 +26,125 @@ func UnbindStudent(userID int, stuID string) error {
 		return errors.New("该学号还有任务未删除，请先删除任务再解绑")
 	}
 
-	// 删除绑定关系
-	result := db.Where("user_id = ? AND stu_id = ?", userID, stuID).Delete(&database.UserStudent{})
-	if result.Error != nil {
-		return result.Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 删除绑定关系
+		result := tx.Where("user_id = ? AND stu_id = ?", userID, stuID).Delete(&database.UserStudent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("绑定关系不存在")
+		}
+		database.RecordStudentAudit(tx, stuID, userID, database.StudentAuditUnbind, "")
+
+		return handOverOwnership(tx, userID, stuID)
+	})
+}
+
+// handOverOwnership 所有者解绑后，把学号交给最早绑定的其他用户；无人绑定时清空所有者，
+// 待处理的绑定申请随之转给新所有者或全部拒绝
+func handOverOwnership(tx *gorm.DB, userID int, stuID string) error {
+	var stu database.Student
+	if err := tx.First(&stu, "stu_id = ?", stuID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if stu.OwnerUserID == nil || *stu.OwnerUserID != userID {
+		return nil
+	}
+
+	var next database.UserStudent
+	err := tx.Where("stu_id = ?", stuID).Order("id").First(&next).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("绑定关系不存在")
+
+	pending := tx.Model(&database.StudentBindRequest{}).
+		Where("stu_id = ? AND status = ?", stuID, database.BindRequestPending)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := tx.Model(&stu).Update("owner_user_id", nil).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := pending.Updates(map[string]any{"status": database.BindRequestRejected, "decided_at": now}).Error; err != nil {
+			return err
+		}
+		database.RecordStudentAudit(tx, stuID, userID, database.StudentAuditOwnerChanged, "所有者解绑，学号暂无所有者")
+		return nil
 	}
 
+	if err := tx.Model(&stu).Update("owner_user_id", next.UserID).Error; err != nil {
+		return err
+	}
+	if err := pending.Update("owner_user_id", next.UserID).Error; err != nil {
+		return err
+	}
+	database.RecordStudentAudit(tx, stuID, userID, database.StudentAuditOwnerChanged, fmt.Sprintf("所有者解绑，转交给用户 %d", next.UserID))
 	return nil
 }
 
-// 查询用户已绑定的学号列表
-func GetBoundStudents(userID int) ([]database.UserStudent, error) {
+// BoundStudent 已绑定学号及其登录态健康状况
+type BoundStudent struct {
+	database.UserStudent
+	IsOwner            bool // 当前用户是否为该学号的所有者
+	CredentialsInvalid bool
+	SessionStatus      string
+	SessionCheckedAt   *time.Time
+	SessionExpiresAt   *time.Time
+	LastRefreshAt      *time.Time
+	LastRefreshOK      bool
+	LastRefreshError   string
+	Health             string // 给用户看的健康提示
+}
+
+// 查询用户已绑定的学号列表，附带每个学号登录态的健康状况
+func GetBoundStudents(userID int) ([]BoundStudent, error) {
 	var binds []database.UserStudent
-	err := database.DB.Where("user_id = ?", userID).Find(&binds).Error
-	return binds, err
+	if err := database.DB.Where("user_id = ?", userID).Find(&binds).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]BoundStudent, 0, len(binds))
+	for _, b := range binds {
+		item := BoundStudent{UserStudent: b, SessionStatus: database.SessionStatusUnknown}
+
+		var stu database.Student
+		if err := database.DB.First(&stu, "stu_id = ?", b.StuID).Error; err == nil {
+			item.IsOwner = stu.OwnerUserID != nil && *stu.OwnerUserID == userID
+			item.CredentialsInvalid = stu.CredentialsInvalid
+			item.SessionStatus = stu.SessionStatus
+			item.SessionCheckedAt = stu.SessionCheckedAt
+			item.SessionExpiresAt = stu.SessionExpiresAt
+			item.LastRefreshAt = stu.LastRefreshAt
+			item.LastRefreshOK = stu.LastRefreshOK
+			item.LastRefreshError = stu.LastRefreshError
+		}
+		item.Health = describeHealth(item)
+
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// describeHealth 根据探测与刷新结果生成健康提示
+func describeHealth(b BoundStudent) string {
+	if b.CredentialsInvalid {
+		return "学校平台密码已失效，签到任务已暂停，请更新密码"
+	}
+	if b.LastRefreshAt != nil && !b.LastRefreshOK {
+		return "自动登录失败，学校平台密码是否已修改？（" + b.LastRefreshError + "）"
+	}
+
+	switch b.SessionStatus {
+	case database.SessionStatusOK:
+		return "登录态正常"
+	case database.SessionStatusExpired:
+		return "登录态已失效，学校平台密码是否已修改？"
+	case database.SessionStatusError:
+		return "登录态检测失败，学校平台可能暂时不可用"
+	default:
+		return "尚未检测登录态"
+	}
 }
@@ -0,0 +1,217 @@
+// logic/user/login_guard.go
+package user
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrLoginThrottled 登录失败次数过多，需要等待或已被锁定
+var ErrLoginThrottled = errors.New("登录尝试过于频繁")
+
+// loginDelay 连续失败 failures 次后，下一次尝试前需要等待的时间：前 2 次不限制，之后每次翻倍
+func loginDelay(failures int) time.Duration {
+	if failures < 3 {
+		return 0
+	}
+	delay := time.Second << min(failures-3, 10)
+	return min(delay, config.LoginMaxDelay)
+}
+
+// throttleError 生成带剩余等待时间的限流错误
+func throttleError(wait time.Duration, locked bool) error {
+	seconds := int(wait.Seconds()) + 1
+	if locked {
+		return fmt.Errorf("%w：已被临时锁定，请 %d 分钟后再试或联系管理员", ErrLoginThrottled, (seconds+59)/60)
+	}
+	return fmt.Errorf("%w，请 %d 秒后再试", ErrLoginThrottled, seconds)
+}
+
+// ipAttempts 某个 IP 的连续失败记录（仅保存在内存中）
+type ipAttempts struct {
+	failures     int
+	lastFailedAt time.Time
+	lockedUntil  time.Time
+}
+
+var (
+	ipFailures   = make(map[string]*ipAttempts)
+	ipFailuresMu sync.Mutex
+)
+
+// takeIPAttempt 判断该 IP 当前是否允许尝试登录，允许时先按失败计入一次，登录成功后由 refundIPAttempt 退回。
+// 检查与计数在同一把锁内完成，并发的密码校验不会都按旧的计数放行
+func takeIPAttempt(ip string) error {
+	ipFailuresMu.Lock()
+	defer ipFailuresMu.Unlock()
+
+	now := time.Now()
+	if a, ok := ipFailures[ip]; ok {
+		if now.Before(a.lockedUntil) {
+			return throttleError(a.lockedUntil.Sub(now), true)
+		}
+		// 已计入的失败（含仍在校验中的请求）达到阈值后锁定该 IP
+		if a.failures >= config.LoginIPMaxFailures {
+			a.lockedUntil = now.Add(config.LoginLockDuration)
+			a.failures = 0
+			log.Printf("🚫 IP %s 连续登录失败 %d 次，锁定 %s", ip, config.LoginIPMaxFailures, config.LoginLockDuration)
+			return throttleError(config.LoginLockDuration, true)
+		}
+		if wait := a.lastFailedAt.Add(loginDelay(a.failures)).Sub(now); wait > 0 {
+			return throttleError(wait, false)
+		}
+	}
+
+	// 顺便清理过期记录
+	for k, a := range ipFailures {
+		if now.Sub(a.lastFailedAt) > config.LoginFailureWindow && now.After(a.lockedUntil) {
+			delete(ipFailures, k)
+		}
+	}
+
+	a, ok := ipFailures[ip]
+	if !ok {
+		a = &ipAttempts{}
+		ipFailures[ip] = a
+	}
+	a.failures++
+	a.lastFailedAt = now
+	return nil
+}
+
+// refundIPAttempt 校验通过后退回本次请求在 takeIPAttempt 中计入的一次失败。
+// 只退回一次而不清空整条记录，否则攻击者可以穿插登录自己的账号来重置 IP 限制
+func refundIPAttempt(ip string) {
+	ipFailuresMu.Lock()
+	defer ipFailuresMu.Unlock()
+
+	if a, ok := ipFailures[ip]; ok && a.failures > 0 {
+		a.failures--
+	}
+}
+
+// guardedCheck 对已登录用户的身份校验（重新验证、关闭两步验证等）做与登录相同的 IP 和账号失败计数，
+// 防止持有被盗访问令牌的人无限次猜测密码或验证码
+func guardedCheck(user *database.User, ip string, check func() error) error {
+	if err := takeIPAttempt(ip); err != nil {
+		return err
+	}
+	if err := checkAccountAllowed(user); err != nil {
+		return err
+	}
+	if err := check(); err != nil {
+		recordAccountFailure(user, ip)
+		return err
+	}
+	refundIPAttempt(ip)
+	resetAccountFailures(user)
+	return nil
+}
+
+// checkAccountAllowed 判断账号当前是否允许尝试登录；锁定到期或超出统计窗口的失败计数会被清零
+func checkAccountAllowed(user *database.User) error {
+	now := time.Now()
+	if user.LockedUntil != nil {
+		if now.Before(*user.LockedUntil) {
+			return throttleError(user.LockedUntil.Sub(now), true)
+		}
+		resetAccountFailures(user)
+		return nil
+	}
+
+	if user.LastFailedLoginAt == nil {
+		return nil
+	}
+	if now.Sub(*user.LastFailedLoginAt) > config.LoginFailureWindow {
+		resetAccountFailures(user)
+		return nil
+	}
+	if wait := user.LastFailedLoginAt.Add(loginDelay(user.FailedLoginCount)).Sub(now); wait > 0 {
+		return throttleError(wait, false)
+	}
+	return nil
+}
+
+// recordAccountFailure 记录账号的一次密码错误，达到阈值后锁定账号并邮件通知用户。
+// 失败次数在数据库中原子累加，并以累加后的值判断是否锁定，并发的失败不会互相覆盖
+func recordAccountFailure(user *database.User, ip string) {
+	db := database.DB
+	now := time.Now()
+
+	if err := db.Model(&database.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_count":   gorm.Expr("failed_login_count + 1"),
+		"last_failed_login_at": now,
+	}).Error; err != nil {
+		log.Printf("❌ 保存登录失败记录失败: 用户ID=%d, 错误=%v", user.ID, err)
+		return
+	}
+
+	var count int
+	if err := db.Model(&database.User{}).Where("id = ?", user.ID).
+		Select("failed_login_count").Scan(&count).Error; err != nil {
+		log.Printf("❌ 读取登录失败次数失败: 用户ID=%d, 错误=%v", user.ID, err)
+		return
+	}
+	user.FailedLoginCount = count
+	user.LastFailedLoginAt = &now
+
+	if count < config.LoginMaxFailures {
+		return
+	}
+
+	// 只在尚未锁定时加锁，并发达到阈值的请求只有一个会发送通知
+	until := now.Add(config.LoginLockDuration)
+	result := db.Model(&database.User{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until <= ?)", user.ID, now).
+		Update("locked_until", until)
+	if result.Error != nil {
+		log.Printf("❌ 锁定账号失败: 用户ID=%d, 错误=%v", user.ID, result.Error)
+		return
+	}
+	user.LockedUntil = &until
+
+	if result.RowsAffected > 0 {
+		log.Printf("🔒 用户 %d 连续登录失败 %d 次，账号锁定至 %s", user.ID, user.FailedLoginCount, user.LockedUntil.Format("2006-01-02 15:04:05"))
+		go func(email, username string, until time.Time) {
+			if err := utils.SendAccountLockedEmail(email, username, ip, until); err != nil {
+				log.Printf("❌ 发送账号锁定通知失败: %v", err)
+			}
+		}(user.Email, user.Username, *user.LockedUntil)
+	}
+}
+
+// resetAccountFailures 清空账号的失败计数与锁定状态
+func resetAccountFailures(user *database.User) {
+	if user.FailedLoginCount == 0 && user.LastFailedLoginAt == nil && user.LockedUntil == nil {
+		return
+	}
+	user.FailedLoginCount = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+
+	if err := database.DB.Model(&database.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_count":   0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}).Error; err != nil {
+		log.Printf("❌ 清除登录失败记录失败: 用户ID=%d, 错误=%v", user.ID, err)
+	}
+}
+
+// UnlockUser 管理员手动解除账号锁定
+func UnlockUser(userID int) error {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+	resetAccountFailures(&user)
+	return nil
+}
@@ -0,0 +1,50 @@
+// logic/user/login_guard_test.go
+package user
+
+import (
+	"dormcheck/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 穿插一次成功登录只退回该次请求的计数，不能清空 IP 上累计的失败
+func TestRefundIPAttemptKeepsEarlierFailures(t *testing.T) {
+	config.LoginIPMaxFailures = 4
+	config.LoginLockDuration = time.Hour
+	config.LoginFailureWindow = time.Hour
+	config.LoginMaxDelay = 0
+	ip := "203.0.113.7"
+	t.Cleanup(func() {
+		ipFailuresMu.Lock()
+		delete(ipFailures, ip)
+		ipFailuresMu.Unlock()
+	})
+
+	attempt := func(ok bool) error {
+		if err := takeIPAttempt(ip); err != nil {
+			return err
+		}
+		if ok {
+			refundIPAttempt(ip)
+		}
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := attempt(false); err != nil {
+			t.Fatalf("第 %d 次失败不应被限制: %v", i+1, err)
+		}
+		if err := attempt(true); err != nil {
+			t.Fatalf("成功登录不应被限制: %v", err)
+		}
+	}
+
+	// 累计 4 次失败后，下一次尝试时 IP 被锁定
+	if err := attempt(false); err != nil {
+		t.Fatalf("第 4 次尝试不应被提前拒绝: %v", err)
+	}
+	if err := attempt(true); !errors.Is(err, ErrLoginThrottled) {
+		t.Errorf("期望 IP 已被锁定，实际 %v", err)
+	}
+}
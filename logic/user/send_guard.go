@@ -0,0 +1,214 @@
+// logic/user/send_guard.go
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dormcheck/config"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSendCodeQuota 验证码发送次数超过 IP 或全局配额
+var ErrSendCodeQuota = errors.New("验证码发送次数过多，请稍后再试")
+
+// powChallengeTTL 工作量证明挑战的有效期
+const powChallengeTTL = 5 * time.Minute
+
+// disposableDomains 常见一次性邮箱域名，可通过 DISPOSABLE_EMAIL_DOMAINS 追加
+var disposableDomains = []string{
+	"10minutemail.com", "20minutemail.com", "guerrillamail.com", "guerrillamail.net",
+	"mailinator.com", "maildrop.cc", "yopmail.com", "temp-mail.org", "tempmail.com",
+	"throwawaymail.com", "trashmail.com", "getnada.com", "sharklasers.com",
+	"dispostable.com", "fakeinbox.com", "mohmal.com", "emailondeck.com", "mintemail.com",
+	"moakt.com", "linshiyouxiang.net", "bccto.me", "chacuo.net", "027168.com",
+}
+
+// isDisposableEmail 判断邮箱是否属于一次性邮箱域名（含子域名）
+func isDisposableEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, list := range [][]string{disposableDomains, config.DisposableEmailDomains} {
+		for _, d := range list {
+			d = strings.ToLower(d)
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// slidingCounter 按时间窗口统计次数（仅保存在内存中）
+type slidingCounter struct {
+	mu        sync.Mutex
+	events    map[string][]time.Time
+	lastSweep time.Time
+}
+
+// allow 统计窗口内 key 的次数，未超过 limit 时记一次并返回 true；limit <= 0 表示不限
+func (s *slidingCounter) allow(key string, limit int, window time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// 每个窗口清理一次已无有效记录的 key，避免只来过一次的 IP 永久占用内存
+	if now.Sub(s.lastSweep) >= window {
+		for k, events := range s.events {
+			if len(events) == 0 || now.Sub(events[len(events)-1]) >= window {
+				delete(s.events, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	kept := s.events[key][:0]
+	for _, t := range s.events[key] {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= limit {
+		s.events[key] = kept
+		return false
+	}
+	s.events[key] = append(kept, now)
+	return true
+}
+
+var (
+	sendCodeCounter = &slidingCounter{events: make(map[string][]time.Time)}
+
+	usedChallenges   = make(map[string]time.Time) // 已使用的挑战 -> 过期时间，防止重放
+	usedChallengesMu sync.Mutex
+)
+
+// PowChallenge 发送验证码前需要完成的工作量证明：
+// 找到 nonce 使 SHA-256(challenge + ":" + nonce) 的前 difficulty 个比特为 0
+type PowChallenge struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"` // 0 表示未启用，无需计算
+}
+
+// NewPowChallenge 生成一个带签名的挑战，服务端无需保存
+func NewPowChallenge() (*PowChallenge, error) {
+	if config.SendCodePowDifficulty <= 0 {
+		return &PowChallenge{}, nil
+	}
+
+	payload := make([]byte, 16+8)
+	if _, err := rand.Read(payload[:16]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint64(payload[16:], uint64(time.Now().Unix()))
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return &PowChallenge{
+		Challenge:  encoded + "." + signChallenge(encoded),
+		Difficulty: config.SendCodePowDifficulty,
+	}, nil
+}
+
+func signChallenge(payload string) string {
+	mac := hmac.New(sha256.New, config.JwtSecret)
+	mac.Write([]byte("pow:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyPow 校验挑战签名、有效期与工作量，每个挑战只能使用一次
+func verifyPow(challenge, nonce string) error {
+	if config.SendCodePowDifficulty <= 0 {
+		return nil
+	}
+	if challenge == "" || nonce == "" {
+		return errors.New("请先完成人机验证")
+	}
+
+	payload, sig, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signChallenge(payload))) {
+		return errors.New("人机验证无效")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) != 24 {
+		return errors.New("人机验证无效")
+	}
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(raw[16:])), 0)
+	if time.Since(issuedAt) > powChallengeTTL {
+		return errors.New("人机验证已过期，请重试")
+	}
+
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	if leadingZeroBits(sum[:]) < config.SendCodePowDifficulty {
+		return errors.New("人机验证未通过")
+	}
+
+	usedChallengesMu.Lock()
+	defer usedChallengesMu.Unlock()
+	now := time.Now()
+	for c, exp := range usedChallenges {
+		if now.After(exp) {
+			delete(usedChallenges, c)
+		}
+	}
+	if _, used := usedChallenges[challenge]; used {
+		return errors.New("人机验证已使用，请重试")
+	}
+	usedChallenges[challenge] = issuedAt.Add(powChallengeTTL)
+	return nil
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, v := range b {
+		if v != 0 {
+			return n + bits.LeadingZeros8(v)
+		}
+		n += 8
+	}
+	return n
+}
+
+// CheckSendCodeAllowed 发送验证码邮件前的防滥用检查：工作量证明、一次性邮箱与 IP 配额。
+// 全局配额在其他检查全部通过、即将发信时由 takeGlobalSendQuota 计数
+func CheckSendCodeAllowed(ip, email, powChallenge, powNonce string) error {
+	if err := verifyPow(powChallenge, powNonce); err != nil {
+		return err
+	}
+
+	if isDisposableEmail(email) {
+		return errors.New("不支持使用临时邮箱")
+	}
+
+	if !sendCodeCounter.allow("ip:"+ip, config.SendCodeIPLimit, time.Hour) {
+		log.Printf("🚫 IP %s 发送验证码超过每小时 %d 次", ip, config.SendCodeIPLimit)
+		return ErrSendCodeQuota
+	}
+	return nil
+}
+
+// takeGlobalSendQuota 计入一封验证码邮件的全局配额；只在真正发信前调用，
+// 被冷却时间、邮箱已注册等检查拒绝的请求不消耗全局配额
+func takeGlobalSendQuota() error {
+	if !sendCodeCounter.allow("global", config.SendCodeGlobalLimit, time.Hour) {
+		log.Printf("🚨 验证码邮件发送量超过全局每小时 %d 封，已暂停发送", config.SendCodeGlobalLimit)
+		return fmt.Errorf("%w（系统繁忙）", ErrSendCodeQuota)
+	}
+	return nil
+}
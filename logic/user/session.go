@@ -0,0 +1,184 @@
+// logic/user/session.go
+package user
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeviceInfo 发起登录/刷新请求的设备信息
+type DeviceInfo struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌有效秒数
+}
+
+// SessionInfo 设备会话列表项
+type SessionInfo struct {
+	ID         uint      `json:"id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"` // 是否为发起请求的设备
+}
+
+// LoginResult 登录结果：未启用两步验证时直接返回令牌，否则返回两步验证挑战
+type LoginResult struct {
+	*TokenPair
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"` // 提交到 /auth/login/2fa
+}
+
+// createSession 为用户新建设备会话并签发令牌，同时返回会话ID
+func createSession(user database.User, device DeviceInfo) (*TokenPair, uint, error) {
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	session := database.UserSession{
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		DeviceName:       device.Name,
+		IP:               device.IP,
+		UserAgent:        device.UserAgent,
+		CreatedAt:        now,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(config.RefreshTokenTTL),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tokens, err := issueTokens(user, session.ID, refreshToken)
+	return tokens, session.ID, err
+}
+
+func issueTokens(user database.User, sessionID uint, refreshToken string) (*TokenPair, error) {
+	accessToken, err := utils.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshSession 用刷新令牌换取新的访问令牌，同时轮换刷新令牌。
+// 已被轮换掉的旧刷新令牌再次出现说明可能泄露，直接注销该会话
+func RefreshSession(refreshToken string, device DeviceInfo) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, errors.New("refresh_token 不能为空")
+	}
+	hash := utils.HashToken(refreshToken)
+	db := database.DB
+
+	var session database.UserSession
+	if err := db.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if err := db.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error; err == nil {
+			log.Printf("🚨 用户 %d 的会话 %d 检测到刷新令牌重复使用，已注销", session.UserID, session.ID)
+			_ = revokeSession(db, session.UserID, session.ID)
+		}
+		return nil, errors.New("登录已失效，请重新登录")
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, errors.New("登录已失效，请重新登录")
+	}
+
+	var user database.User
+	if err := db.First(&user, session.UserID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	newToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"refresh_token_hash":  utils.HashToken(newToken),
+		"previous_token_hash": hash,
+		"last_seen_at":        now,
+		"expires_at":          now.Add(config.RefreshTokenTTL),
+		"ip":                  device.IP,
+		"user_agent":          device.UserAgent,
+	}
+	// 条件更新保证并发刷新时只有一个请求成功
+	result := db.Model(&database.UserSession{}).Where("id = ? AND refresh_token_hash = ?", session.ID, hash).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("登录已失效，请重新登录")
+	}
+
+	return issueTokens(user, session.ID, newToken)
+}
+
+// ListSessions 查询用户所有有效的设备会话
+func ListSessions(userID int, currentSessionID uint) ([]SessionInfo, error) {
+	var sessions []database.UserSession
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, SessionInfo{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current:    s.ID == currentSessionID,
+		})
+	}
+	return list, nil
+}
+
+// RevokeSession 注销用户的某个设备会话
+func RevokeSession(userID int, sessionID uint) error {
+	return revokeSession(database.DB, userID, sessionID)
+}
+
+func revokeSession(db *gorm.DB, userID int, sessionID uint) error {
+	result := db.Model(&database.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("会话不存在或已注销")
+	}
+	return nil
+}
+
+// revokeAllSessions 注销用户的全部设备会话
+func revokeAllSessions(db *gorm.DB, userID int) error {
+	return db.Model(&database.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
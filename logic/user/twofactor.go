@@ -0,0 +1,341 @@
+// logic/user/twofactor.go
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证相关参数
+const (
+	totpIssuer          = "DormCheck"
+	backupCodeCount     = 10
+	loginChallengeTTL   = 5 * time.Minute
+	loginChallengeTries = 5
+)
+
+// TOTPSetup 开始设置两步验证时返回给前端的信息
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // 前端渲染为二维码
+}
+
+// SetupTOTP 为用户生成新的两步验证密钥（尚未启用，需调用 EnableTOTP 验证一次）
+func SetupTOTP(userID int) (*TOTPSetup, error) {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("两步验证已启用，如需更换请先关闭")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	if err := database.DB.Model(&user).Select("totp_secret").Updates(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &TOTPSetup{Secret: secret, URI: utils.TOTPURI(totpIssuer, user.Username, secret)}, nil
+}
+
+// EnableTOTP 校验验证器应用生成的验证码后启用两步验证，返回一次性展示的备用恢复码
+func EnableTOTP(userID int, code string) ([]string, error) {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("两步验证已启用")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先获取两步验证密钥")
+	}
+	if err := checkTOTP(&user, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", userID).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceBackupCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 校验验证码或恢复码后关闭两步验证
+func DisableTOTP(userID int, ip, code string) error {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+	if !user.TOTPEnabled {
+		return errors.New("两步验证未启用")
+	}
+	if err := guardedCheck(&user, ip, func() error { return verifySecondFactor(&user, code) }); err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&database.UserBackupCode{}).Error
+	})
+}
+
+// RegenerateBackupCodes 校验验证码后重新生成备用恢复码，旧的全部作废
+func RegenerateBackupCodes(userID int, ip, code string) ([]string, error) {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("两步验证未启用")
+	}
+	if err := guardedCheck(&user, ip, func() error { return checkTOTP(&user, code) }); err != nil {
+		return nil, err
+	}
+	return replaceBackupCodes(database.DB, userID)
+}
+
+func hashBackupCode(userID int, code string) string {
+	mac := hmac.New(sha256.New, config.JwtSecret)
+	mac.Write([]byte("backup:" + strconv.Itoa(userID) + ":" + normalizeBackupCode(code)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func normalizeBackupCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// replaceBackupCodes 删除旧恢复码并生成新的一组，形如 abcd-efgh
+func replaceBackupCodes(db *gorm.DB, userID int) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&database.UserBackupCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, backupCodeCount)
+	for i := 0; i < backupCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		code := raw[:4] + "-" + raw[4:]
+
+		if err := db.Create(&database.UserBackupCode{
+			UserID:    userID,
+			CodeHash:  hashBackupCode(userID, code),
+			CreatedAt: time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// checkTOTP 校验验证器应用生成的 6 位验证码，同一验证码只能使用一次
+func checkTOTP(user *database.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return errors.New("两步验证码错误")
+	}
+
+	// 条件更新保证同一时间步的验证码并发提交时只有一个成功
+	result := database.DB.Model(&database.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该验证码已使用，请等待下一个验证码")
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// verifySecondFactor 校验 6 位验证码或备用恢复码
+func verifySecondFactor(user *database.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		return checkTOTP(user, code)
+	}
+
+	result := database.DB.Model(&database.UserBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashBackupCode(user.ID, code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("两步验证码错误")
+	}
+	log.Printf("🔑 用户 %d 使用了一个备用恢复码", user.ID)
+	return nil
+}
+
+// loginChallenge 密码验证通过、等待两步验证的登录请求（仅保存在内存中）
+type loginChallenge struct {
+	userID    int
+	device    DeviceInfo
+	attempts  int
+	expiresAt time.Time
+}
+
+var (
+	loginChallenges   = make(map[string]*loginChallenge)
+	loginChallengesMu sync.Mutex
+)
+
+// newLoginChallenge 创建两步验证登录挑战，返回挑战令牌
+func newLoginChallenge(userID int, device DeviceInfo) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	loginChallengesMu.Lock()
+	defer loginChallengesMu.Unlock()
+
+	now := time.Now()
+	for t, c := range loginChallenges {
+		if now.After(c.expiresAt) {
+			delete(loginChallenges, t)
+		}
+	}
+	loginChallenges[token] = &loginChallenge{userID: userID, device: device, expiresAt: now.Add(loginChallengeTTL)}
+	return token, nil
+}
+
+// CompleteTwoFactorLogin 提交两步验证码完成登录；错误次数过多时挑战作废，需要重新输入密码
+func CompleteTwoFactorLogin(challengeToken, code string) (tokens *TokenPair, err error) {
+	loginChallengesMu.Lock()
+	c, ok := loginChallenges[challengeToken]
+	if ok && time.Now().After(c.expiresAt) {
+		delete(loginChallenges, challengeToken)
+		ok = false
+	}
+	loginChallengesMu.Unlock()
+	if !ok {
+		return nil, errors.New("登录已过期，请重新登录")
+	}
+
+	defer func() {
+		recordLoginAudit(database.AuditLogin2FA, c.userID, c.device, &LoginResult{TokenPair: tokens}, err)
+	}()
+
+	var user database.User
+	if err := database.DB.First(&user, c.userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	// 两步验证码错误与密码错误一样计入 IP 和账号的失败次数，防止拿到密码后反复换挑战穷举验证码
+	if err := guardedCheck(&user, c.device.IP, func() error { return verifySecondFactor(&user, code) }); err != nil {
+		if errors.Is(err, ErrLoginThrottled) {
+			return nil, err
+		}
+		loginChallengesMu.Lock()
+		c.attempts++
+		if c.attempts >= loginChallengeTries {
+			delete(loginChallenges, challengeToken)
+			err = errors.New("两步验证码错误次数过多，请重新登录")
+		}
+		loginChallengesMu.Unlock()
+		return nil, err
+	}
+
+	loginChallengesMu.Lock()
+	delete(loginChallenges, challengeToken)
+	loginChallengesMu.Unlock()
+	// 再退回密码校验那一步计入的 IP 失败
+	refundIPAttempt(c.device.IP)
+
+	tokens, sessionID, err := createSession(user, c.device)
+	if err != nil {
+		return nil, err
+	}
+	// 刚完成两步验证，视为已重新验证身份
+	markReverified(sessionID)
+	return tokens, nil
+}
+
+// Reverify 敏感操作前重新验证身份：启用两步验证的用户需要验证码或恢复码，其他用户需要登录密码。
+// 错误计入 IP 和账号的登录失败次数
+func Reverify(userID int, sessionID uint, ip, encodedPassword, code string) error {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+
+	if user.TOTPEnabled {
+		if code == "" {
+			return errors.New("请输入两步验证码")
+		}
+		if err := guardedCheck(&user, ip, func() error { return verifySecondFactor(&user, code) }); err != nil {
+			return err
+		}
+	} else {
+		password, err := decodePassword(encodedPassword)
+		if err != nil {
+			return err
+		}
+		if err := guardedCheck(&user, ip, func() error {
+			if !utils.CheckPassword(user.Password, password) {
+				return errors.New("密码错误")
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	markReverified(sessionID)
+	return nil
+}
+
+func markReverified(sessionID uint) {
+	if err := database.DB.Model(&database.UserSession{}).Where("id = ?", sessionID).Update("reverified_at", time.Now()).Error; err != nil {
+		log.Printf("❌ 记录重新验证时间失败: 会话=%d, 错误=%v", sessionID, err)
+	}
+}
+
+// RecentlyReverified 判断会话是否在 REAUTH_WINDOW 内重新验证过身份
+func RecentlyReverified(sessionID uint) bool {
+	var session database.UserSession
+	if err := database.DB.First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	return session.ReverifiedAt != nil && time.Since(*session.ReverifiedAt) < config.ReauthWindow
+}
@@ -0,0 +1,106 @@
+// logic/user/verify_code.go
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// hashVerificationCode 验证码只保存带密钥的哈希，数据库泄露也无法离线穷举 6 位数字
+func hashVerificationCode(email, purpose, code string) string {
+	mac := hmac.New(sha256.New, config.JwtSecret)
+	mac.Write([]byte("evc:" + purpose + ":" + email + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueVerificationCode 生成并发送验证码；同一邮箱同一用途的旧验证码立即失效
+func issueVerificationCode(email, purpose string) error {
+	if err := takeGlobalSendQuota(); err != nil {
+		return err
+	}
+	db := database.DB
+
+	code, err := utils.GenerateVerificationCode()
+	if err != nil {
+		return err
+	}
+
+	if err := db.Where("email = ? AND purpose = ?", email, purpose).Delete(&database.EmailVerificationCode{}).Error; err != nil {
+		return err
+	}
+
+	evc := database.EmailVerificationCode{
+		Email:     email,
+		CodeHash:  hashVerificationCode(email, purpose, code),
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(15 * time.Minute),
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&evc).Error; err != nil {
+		return err
+	}
+
+	if err := utils.SendVerificationCodeEmail(email, code); err != nil {
+		_ = db.Delete(&evc).Error
+		return err
+	}
+	return nil
+}
+
+// checkVerificationCode 校验验证码，错误次数达到上限后作废该验证码。
+// 校验通过后由调用方在业务完成时删除返回的记录
+func checkVerificationCode(db *gorm.DB, email, purpose, code string) (*database.EmailVerificationCode, error) {
+	var evc database.EmailVerificationCode
+	if err := db.Where("email = ? AND purpose = ? AND expires_at > ?", email, purpose, time.Now()).
+		Order("created_at DESC").First(&evc).Error; err != nil {
+		return nil, errors.New("验证码错误或已过期")
+	}
+
+	if evc.Attempts >= config.VerificationCodeMaxAttempts {
+		_ = db.Delete(&evc).Error
+		return nil, errors.New("验证码错误次数过多，请重新获取")
+	}
+
+	if hmac.Equal([]byte(evc.CodeHash), []byte(hashVerificationCode(email, purpose, code))) {
+		return &evc, nil
+	}
+
+	// 在数据库中原子地累加错误次数，并发请求不会互相覆盖；没有更新到说明次数已用完
+	result := db.Model(&database.EmailVerificationCode{}).
+		Where("id = ? AND attempts < ?", evc.ID, config.VerificationCodeMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		log.Printf("❌ 更新验证码错误次数失败: %v", result.Error)
+		return nil, errors.New("验证码错误或已过期")
+	}
+
+	var attempts int
+	if result.RowsAffected > 0 {
+		if err := db.Model(&database.EmailVerificationCode{}).Where("id = ?", evc.ID).
+			Select("attempts").Scan(&attempts).Error; err != nil {
+			log.Printf("❌ 读取验证码错误次数失败: %v", err)
+			return nil, errors.New("验证码错误或已过期")
+		}
+	}
+	if result.RowsAffected == 0 || attempts >= config.VerificationCodeMaxAttempts {
+		log.Printf("🚫 邮箱 %s 的 %s 验证码错误次数达到上限，已作废", email, purpose)
+		_ = db.Delete(&database.EmailVerificationCode{}, evc.ID).Error
+		return nil, errors.New("验证码错误次数过多，请重新获取")
+	}
+	return nil, errors.New("验证码错误或已过期")
+}
+
+// PurgeExpiredVerificationCodes 删除所有已过期的验证码，返回删除的条数
+func PurgeExpiredVerificationCodes() (int64, error) {
+	result := database.DB.Where("expires_at <= ?", time.Now()).Delete(&database.EmailVerificationCode{})
+	return result.RowsAffected, result.Error
+}
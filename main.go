@@ -1,8 +1,10 @@
 package main
 
 import (
+	"dormcheck/captcha"
 	"dormcheck/config"
 	"dormcheck/database"
+	"dormcheck/external/egress"
 	"dormcheck/logger" // ✅ 添加这一行
 	"dormcheck/routes"
 	"dormcheck/scheduler" // ✅ 引入调度器
@@ -14,9 +16,11 @@ import (
 )
 
 func main() {
-	logger.InitLogger() // ✅ 初始化日志模块
-	config.InitConfig() // ✅ 载入环境配置
-	database.InitDB()   // ✅ 初始化数据库
+	logger.InitLogger()   // ✅ 初始化日志模块
+	config.InitConfig()   // ✅ 载入环境配置
+	database.InitDB()     // ✅ 初始化数据库
+	egress.InitEgress()   // ✅ 初始化平台出口代理
+	captcha.InitSolvers() // ✅ 初始化验证码识别后端
 
 	app := fiber.New()
 	// ✅ 添加 CORS 中间件
@@ -28,11 +32,14 @@ func main() {
 
 	routes.RegisterAuthRoutes(app)
 	routes.RegisterStudentRoutes(app)
+	routes.RegisterAdminRoutes(app)
 
 	// ✅ 启动自动任务调度器 & 每日重置器（必须在主线程之外执行）
 	go scheduler.StartWorker()
 	go scheduler.StartResetWorker()
 	go scheduler.StartCookieRefresher()
+	go scheduler.StartSessionProbe()
+	go scheduler.StartVerificationCodePurger()
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("DormCheck 后端服务已启动！")
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"dormcheck/database"
+	"dormcheck/logic/user"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// accessTokenRoutes 个人访问令牌可以调用的接口及所需权限范围，未列出的接口只接受登录令牌
+var accessTokenRoutes = map[string]database.TokenScope{
+	"GET /student/list":         database.ScopeTasksRead,
+	"GET /student/activities":   database.ScopeTasksRead,
+	"GET /student/tasks":        database.ScopeTasksRead,
+	"POST /student/task":        database.ScopeTasksWrite,
+	"POST /student/task/delete": database.ScopeTasksWrite,
+	"POST /student/task/run":    database.ScopeTasksRun,
+}
+
+func isAccessToken(tokenStr string) bool {
+	return strings.HasPrefix(tokenStr, user.AccessTokenPrefix)
+}
+
+// accessTokenAuth 校验个人访问令牌及其对当前接口的权限范围，并写入与 JwtAuth 相同的上下文（sessionID 为 0）
+func accessTokenAuth(c *fiber.Ctx, tokenStr string) error {
+	record, err := user.ParseAccessToken(tokenStr, c.IP())
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "无效的访问令牌: " + err.Error()})
+	}
+
+	required, ok := accessTokenRoutes[c.Method()+" "+strings.TrimSuffix(c.Path(), "/")]
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "该接口不支持个人访问令牌，请使用登录令牌"})
+	}
+	granted := false
+	for _, s := range user.AccessTokenScopes(record) {
+		if s == required {
+			granted = true
+			break
+		}
+	}
+	if !granted {
+		return c.Status(403).JSON(fiber.Map{"error": "访问令牌缺少权限范围: " + string(required)})
+	}
+
+	var u database.User
+	if err := database.DB.First(&u, record.UserID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "用户不存在"})
+	}
+
+	c.Locals("userID", u.ID)
+	c.Locals("sessionID", uint(0))
+	c.Locals("user", &u)
+	c.Locals("accessTokenID", record.ID)
+
+	return c.Next()
+}
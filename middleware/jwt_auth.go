@@ -8,7 +8,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// JwtAuth 是 Fiber 中间件，用于解析并验证 JWT，并把用户信息放入上下文
+// JwtAuth 是 Fiber 中间件，用于解析并验证 JWT（或个人访问令牌），并把用户信息放入上下文
 func JwtAuth(c *fiber.Ctx) error {
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
@@ -21,6 +21,10 @@ func JwtAuth(c *fiber.Ctx) error {
 		return c.Status(401).JSON(fiber.Map{"error": "Authorization 格式错误，应为 Bearer {token}"})
 	}
 
+	if isAccessToken(tokenStr) {
+		return accessTokenAuth(c, tokenStr)
+	}
+
 	// 验证 JWT 有效性并校验 tokenVersion
 	claims, err := utils.ParseToken(tokenStr)
 	if err != nil {
@@ -35,6 +39,31 @@ func JwtAuth(c *fiber.Ctx) error {
 
 	// 将 userID 和完整用户信息存入上下文
 	c.Locals("userID", claims.UserID)
+	c.Locals("sessionID", claims.SessionID)
+	c.Locals("user", &user)
+
+	return c.Next()
+}
+
+// OptionalJwtAuth 携带有效 token 时与 JwtAuth 一样写入用户信息，未携带或无效时按未登录继续处理
+func OptionalJwtAuth(c *fiber.Ctx) error {
+	tokenStr := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	if tokenStr == "" {
+		return c.Next()
+	}
+
+	claims, err := utils.ParseToken(tokenStr)
+	if err != nil {
+		return c.Next()
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+		return c.Next()
+	}
+
+	c.Locals("userID", claims.UserID)
+	c.Locals("sessionID", claims.SessionID)
 	c.Locals("user", &user)
 
 	return c.Next()
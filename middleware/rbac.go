@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"dormcheck/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole 仅允许指定角色访问，需放在 JwtAuth 之后
+func RequireRole(allowed ...database.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Locals("user").(*database.User)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{"error": "未登录"})
+		}
+		for _, r := range allowed {
+			if user.Role == r {
+				return c.Next()
+			}
+		}
+		return c.Status(403).JSON(fiber.Map{"error": "当前角色无权访问"})
+	}
+}
+
+// RequirePermission 仅允许拥有指定权限的角色访问，需放在 JwtAuth 之后
+func RequirePermission(perm database.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Locals("user").(*database.User)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{"error": "未登录"})
+		}
+		if !user.Role.Has(perm) {
+			return c.Status(403).JSON(fiber.Map{"error": "权限不足"})
+		}
+		return c.Next()
+	}
+}
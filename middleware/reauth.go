@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"dormcheck/database"
+	"dormcheck/logic/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireReauth 敏感操作要求当前会话在近期重新验证过身份（POST /auth/reverify）：
+// 启用两步验证的用户提交验证码，其他用户提交登录密码。需放在 JwtAuth 之后
+func RequireReauth(c *fiber.Ctx) error {
+	if _, ok := c.Locals("user").(*database.User); !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "未登录"})
+	}
+
+	sessionID, _ := c.Locals("sessionID").(uint)
+	if !user.RecentlyReverified(sessionID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":           "该操作需要重新验证身份",
+			"reauth_required": true,
+		})
+	}
+	return c.Next()
+}
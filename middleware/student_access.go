@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"dormcheck/logic/student"
+	"dormcheck/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireStudentBinding 校验请求中的 stu_id（查询参数或 JSON 请求体）是当前用户已绑定的学号，
+// 通过后写入 c.Locals("stuID")，需放在 JwtAuth 之后
+func RequireStudentBinding(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	stuID := c.Query("stu_id")
+	if stuID == "" && len(c.Body()) > 0 {
+		var body struct {
+			StuID string `json:"stu_id"`
+		}
+		_ = c.BodyParser(&body)
+		stuID = body.StuID
+	}
+	if stuID == "" {
+		return utils.RespondJSON(c, 400, false, "缺少参数 stu_id", nil)
+	}
+
+	if err := student.EnsureStudentBinding(userID, stuID); err != nil {
+		if errors.Is(err, student.ErrStudentNotBound) {
+			return utils.RespondJSON(c, 403, false, err.Error(), nil)
+		}
+		return utils.RespondJSON(c, 500, false, "校验绑定关系失败: "+err.Error(), nil)
+	}
+
+	c.Locals("stuID", stuID)
+	return c.Next()
+}
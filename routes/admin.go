@@ -0,0 +1,236 @@
+// routes/admin.go
+package routes
+
+import (
+	"bytes"
+	"dormcheck/captcha"
+	"dormcheck/database"
+	"dormcheck/logic/user"
+	"dormcheck/middleware"
+	"dormcheck/utils"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterAdminRoutes 注册管理员接口路由
+func RegisterAdminRoutes(app *fiber.App) {
+	admin := app.Group("/admin", middleware.JwtAuth, middleware.RequirePermission(database.PermAdminAccess))
+	users := admin.Group("/users", middleware.RequirePermission(database.PermManageUsers))
+	captchaAdmin := admin.Group("/captcha", middleware.RequirePermission(database.PermManageCaptcha))
+
+	// 分页查询安全审计日志，可按操作者、操作、对象、结果、IP 和时间范围（RFC3339）过滤
+	admin.Get("/audit", middleware.RequirePermission(database.PermViewAudit), func(c *fiber.Ctx) error {
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+		if page < 1 {
+			page = 1
+		}
+		if size < 1 || size > 200 {
+			size = 50
+		}
+
+		query := database.DB.Model(&database.AuditEvent{})
+		if actorID := c.Query("actor_id"); actorID != "" {
+			query = query.Where("actor_id = ?", actorID)
+		}
+		for _, field := range []string{"action", "target_type", "target_id", "result", "ip"} {
+			if v := c.Query(field); v != "" {
+				query = query.Where(field+" = ?", v)
+			}
+		}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				return utils.RespondJSON(c, 400, false, "from 格式错误，应为 RFC3339", nil)
+			}
+			query = query.Where("created_at >= ?", t)
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse(time.RFC3339, to)
+			if err != nil {
+				return utils.RespondJSON(c, 400, false, "to 格式错误，应为 RFC3339", nil)
+			}
+			query = query.Where("created_at < ?", t)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		var events []database.AuditEvent
+		if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&events).Error; err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "查询成功", fiber.Map{
+			"total":  total,
+			"page":   page,
+			"size":   size,
+			"events": events,
+		})
+	})
+
+	// 解除用户因多次登录失败导致的锁定
+	users.Post("/:id/unlock", func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, "用户ID格式错误", nil)
+		}
+
+		err = user.UnlockUser(id)
+		recordAudit(c, database.AuditAdminUnlockUser, database.AuditTargetUser, auditUserID(id), err)
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "已解除锁定", nil)
+	})
+
+	// 修改用户角色
+	users.Post("/:id/role", func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, "用户ID格式错误", nil)
+		}
+
+		var data struct {
+			Role *int `json:"role"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.Role == nil {
+			return utils.RespondJSON(c, 400, false, "参数错误，role 不能为空", nil)
+		}
+
+		if id == c.Locals("userID").(int) {
+			return utils.RespondJSON(c, 400, false, "不能修改自己的角色", nil)
+		}
+
+		err = user.SetUserRole(id, database.Role(*data.Role))
+		recordAudit(c, database.AuditAdminSetRole, database.AuditTargetUser, auditUserID(id), err)
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "角色已更新", nil)
+	})
+
+	// 查询等待人工识别的验证码
+	captchaAdmin.Get("/pending", func(c *fiber.Ctx) error {
+		return utils.RespondJSON(c, 200, true, "查询成功", captcha.PendingManual())
+	})
+
+	// 提交人工识别的验证码结果
+	captchaAdmin.Post("/answer", func(c *fiber.Ctx) error {
+		var data struct {
+			ID   string `json:"id"`
+			Text string `json:"text"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.ID == "" || data.Text == "" {
+			return utils.RespondJSON(c, 400, false, "参数错误，id 和 text 不能为空", nil)
+		}
+
+		if err := captcha.AnswerManual(data.ID, data.Text); err != nil {
+			return utils.RespondJSON(c, 400, false, err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "提交成功", nil)
+	})
+
+	// 验证码识别统计：各后端每日调用次数、接受率、耗时、费用及今日预算
+	captchaAdmin.Get("/stats", func(c *fiber.Ctx) error {
+		days := c.QueryInt("days", 7)
+		if days < 1 || days > 90 {
+			days = 7
+		}
+
+		stats, err := captcha.Stats(days)
+		if err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "查询成功", stats)
+	})
+
+	// 分页查询验证码样本，status 可选：unlabeled（待标注）/ labeled（已标注）/ rejected（平台未接受）
+	captchaAdmin.Get("/samples", func(c *fiber.Ctx) error {
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+		if page < 1 {
+			page = 1
+		}
+		if size < 1 || size > 200 {
+			size = 50
+		}
+
+		query := database.DB.Model(&database.CaptchaSample{})
+		switch c.Query("status") {
+		case "unlabeled":
+			query = query.Where("label = ''")
+		case "labeled":
+			query = query.Where("label <> ''")
+		case "rejected":
+			query = query.Where("accepted = ?", false)
+		}
+		if source := c.Query("source"); source != "" {
+			query = query.Where("source = ?", source)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		var samples []database.CaptchaSample
+		if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&samples).Error; err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "查询成功", fiber.Map{
+			"total":   total,
+			"page":    page,
+			"size":    size,
+			"samples": samples,
+		})
+	})
+
+	// 标注验证码样本
+	captchaAdmin.Post("/samples/:id/label", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return utils.RespondJSON(c, 400, false, "样本 ID 错误", nil)
+		}
+
+		var data struct {
+			Label string `json:"label"`
+		}
+		if err := c.BodyParser(&data); err != nil {
+			return utils.RespondJSON(c, 400, false, "参数错误", nil)
+		}
+
+		adminID := c.Locals("userID").(int)
+		err = captcha.LabelSample(uint(id), data.Label, adminID)
+		recordAudit(c, database.AuditAdminCaptchaLabel, database.AuditTargetCaptcha, strconv.Itoa(id), err)
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "标注成功", nil)
+	})
+
+	// 导出所有已标注样本（zip），可直接用于 cmd/captcha-train 训练离线模型
+	captchaAdmin.Get("/samples/export", func(c *fiber.Ctx) error {
+		var buf bytes.Buffer
+		count, err := captcha.ExportLabeledSamples(&buf)
+		if err != nil {
+			return utils.RespondJSON(c, 500, false, "导出失败: "+err.Error(), nil)
+		}
+
+		c.Set("Content-Type", "application/zip")
+		c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="captcha_samples_%s.zip"`, time.Now().Format("20060102")))
+		c.Set("X-Sample-Count", strconv.Itoa(count))
+		return c.Send(buf.Bytes())
+	})
+}
@@ -0,0 +1,38 @@
+// routes/audit.go
+package routes
+
+import (
+	"dormcheck/database"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordAudit 记录当前请求的安全审计事件；操作者取自登录信息，err 非空时记为失败
+func recordAudit(c *fiber.Ctx, action, targetType, targetID string, err error) {
+	e := database.AuditEvent{
+		IP:         c.IP(),
+		UserAgent:  c.Get("User-Agent"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Result:     database.AuditResultSuccess,
+	}
+	if userID, ok := c.Locals("userID").(int); ok {
+		e.ActorID = userID
+	}
+	if err != nil {
+		e.Result = database.AuditResultFailure
+		e.Detail = err.Error()
+	}
+	if tokenID, ok := c.Locals("accessTokenID").(uint); ok {
+		e.Detail = fmt.Sprintf("[个人访问令牌 #%d] %s", tokenID, e.Detail)
+	}
+	database.RecordAuditEvent(e)
+}
+
+// auditUserID 审计对象为用户时的 TargetID
+func auditUserID(id int) string {
+	return strconv.Itoa(id)
+}
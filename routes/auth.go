@@ -1,8 +1,12 @@
 package routes
 
 import (
+	"dormcheck/database"
 	"dormcheck/logic/user"
 	"dormcheck/middleware"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,11 +14,22 @@ import (
 func RegisterAuthRoutes(app *fiber.App) {
 	auth := app.Group("/auth")
 
-	// 发送验证码接口
-	auth.Post("/send-code", func(c *fiber.Ctx) error {
+	// 获取发送验证码前需要完成的人机验证（工作量证明）挑战
+	auth.Get("/send-code/challenge", func(c *fiber.Ctx) error {
+		challenge, err := user.NewPowChallenge()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "生成人机验证失败"})
+		}
+		return c.JSON(challenge)
+	})
+
+	// 发送验证码接口（change_email 用途需要登录）
+	auth.Post("/send-code", middleware.OptionalJwtAuth, func(c *fiber.Ctx) error {
 		var data struct {
-			Email   string `json:"email"`
-			Purpose string `json:"purpose"`
+			Email        string `json:"email"`
+			Purpose      string `json:"purpose"`
+			PowChallenge string `json:"pow_challenge"`
+			PowNonce     string `json:"pow_nonce"`
 		}
 		if err := c.BodyParser(&data); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "参数错误"})
@@ -24,12 +39,26 @@ func RegisterAuthRoutes(app *fiber.App) {
 			return c.Status(400).JSON(fiber.Map{"error": "邮箱和用途不能为空"})
 		}
 
+		userID, loggedIn := c.Locals("userID").(int)
+		if data.Purpose == "change_email" {
+			if !loggedIn {
+				return c.Status(401).JSON(fiber.Map{"error": "请先登录"})
+			}
+			if !user.RecentlyReverified(c.Locals("sessionID").(uint)) {
+				return c.Status(403).JSON(fiber.Map{"error": "该操作需要重新验证身份", "reauth_required": true})
+			}
+		}
+
+		if err := user.CheckSendCodeAllowed(c.IP(), data.Email, data.PowChallenge, data.PowNonce); err != nil {
+			return c.Status(sendCodeErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+
 		var err error
 		switch data.Purpose {
 		case "register":
 			err = user.SendRegisterVerificationCode(data.Email)
 		case "change_email":
-			err = user.SendChangeEmailVerificationCode(data.Email)
+			err = user.SendChangeEmailVerificationCode(userID, data.Email)
 		case "reset":
 			err = user.SendResetPasswordCode(data.Email) // 新增用途 reset
 		default:
@@ -37,7 +66,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 		}
 
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			return c.Status(sendCodeErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 		}
 
 		return c.JSON(fiber.Map{"message": "验证码发送成功"})
@@ -63,7 +92,9 @@ func RegisterAuthRoutes(app *fiber.App) {
 			return c.Status(400).JSON(fiber.Map{"error": "用户名至少3个字符，密码至少6个字符"})
 		}
 
-		if err := user.Register(data.Username, data.Email, data.Password, data.Code); err != nil {
+		err := user.Register(data.Username, data.Email, data.Password, data.Code)
+		recordAudit(c, database.AuditRegister, database.AuditTargetEmail, data.Email, err)
+		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
 
@@ -75,6 +106,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 		var data struct {
 			Identifier string `json:"username"` // 用户名或邮箱，前端字段仍用 username
 			Password   string `json:"password"`
+			DeviceName string `json:"device_name"` // 可选，显示在设备列表中
 		}
 		if err := c.BodyParser(&data); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "参数错误"})
@@ -84,12 +116,135 @@ func RegisterAuthRoutes(app *fiber.App) {
 			return c.Status(400).JSON(fiber.Map{"error": "用户名/邮箱和密码不能为空"})
 		}
 
-		token, err := user.Login(data.Identifier, data.Password)
+		result, err := user.Login(data.Identifier, data.Password, deviceInfo(c, data.DeviceName))
+		if errors.Is(err, user.ErrLoginThrottled) {
+			return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(result)
+	})
+
+	// 登录第二步：提交两步验证码或备用恢复码
+	auth.Post("/login/2fa", func(c *fiber.Ctx) error {
+		var data struct {
+			ChallengeToken string `json:"challenge_token"`
+			Code           string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.ChallengeToken == "" || data.Code == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "challenge_token 和 code 不能为空"})
+		}
+
+		tokens, err := user.CompleteTwoFactorLogin(data.ChallengeToken, data.Code)
 		if err != nil {
 			return c.Status(401).JSON(fiber.Map{"error": err.Error()})
 		}
 
-		return c.JSON(fiber.Map{"token": token})
+		return c.JSON(tokens)
+	})
+
+	// 开始设置两步验证：返回密钥和 otpauth 链接
+	auth.Post("/2fa/setup", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		setup, err := user.SetupTOTP(userID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(setup)
+	})
+
+	// 验证一次验证码后启用两步验证，返回备用恢复码（只展示这一次）
+	auth.Post("/2fa/enable", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		var data struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.Code == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "验证码不能为空"})
+		}
+
+		userID := c.Locals("userID").(int)
+		codes, err := user.EnableTOTP(userID, data.Code)
+		recordAudit(c, database.AuditTwoFactorEnable, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "两步验证已启用，请妥善保存备用恢复码", "backup_codes": codes})
+	})
+
+	// 关闭两步验证
+	auth.Post("/2fa/disable", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
+		var data struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.Code == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "验证码不能为空"})
+		}
+
+		userID := c.Locals("userID").(int)
+		err := user.DisableTOTP(userID, c.IP(), data.Code)
+		recordAudit(c, database.AuditTwoFactorDisable, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "两步验证已关闭"})
+	})
+
+	// 重新生成备用恢复码
+	auth.Post("/2fa/backup-codes", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
+		var data struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.Code == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "验证码不能为空"})
+		}
+
+		userID := c.Locals("userID").(int)
+		codes, err := user.RegenerateBackupCodes(userID, c.IP(), data.Code)
+		recordAudit(c, database.AuditBackupCodesReset, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"backup_codes": codes})
+	})
+
+	// 敏感操作前重新验证身份（启用两步验证时提交 code，否则提交 password）
+	auth.Post("/reverify", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		var data struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "参数错误"})
+		}
+
+		userID := c.Locals("userID").(int)
+		sessionID := c.Locals("sessionID").(uint)
+		err := user.Reverify(userID, sessionID, c.IP(), data.Password, data.Code)
+		recordAudit(c, database.AuditReverify, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "验证成功"})
+	})
+
+	// 用刷新令牌换取新的访问令牌（刷新令牌同时轮换，旧的立即失效）
+	auth.Post("/refresh", func(c *fiber.Ctx) error {
+		var data struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.RefreshToken == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "refresh_token 不能为空"})
+		}
+
+		tokens, err := user.RefreshSession(data.RefreshToken, deviceInfo(c, ""))
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(tokens)
 	})
 
 	// 忘记密码重置接口（重置后强制下线）
@@ -108,6 +263,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 		}
 
 		err := user.ResetPasswordAndForceLogout(data.Email, data.Code, data.NewPassword)
+		recordAudit(c, database.AuditPasswordReset, database.AuditTargetEmail, data.Email, err)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -115,15 +271,122 @@ func RegisterAuthRoutes(app *fiber.App) {
 		return c.JSON(fiber.Map{"message": "密码重置成功，已强制下线所有设备，请使用新密码重新登录"})
 	})
 
-	// 登出
+	// 登出当前设备
 	auth.Post("/logout", middleware.JwtAuth, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
-		if err := user.ForceLogoutAll(userID); err != nil {
+		sessionID := c.Locals("sessionID").(uint)
+		if err := user.RevokeSession(userID, sessionID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "退出失败"})
+		}
+		return c.JSON(fiber.Map{"message": "已退出登录"})
+	})
+
+	// 登出所有设备
+	auth.Post("/logout-all", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+		err := user.ForceLogoutAll(userID)
+		recordAudit(c, database.AuditLogoutAll, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "退出失败"})
 		}
 		return c.JSON(fiber.Map{"message": "已强制下线所有设备"})
 	})
 
+	// 查询已登录的设备
+	auth.Get("/sessions", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+		sessionID := c.Locals("sessionID").(uint)
+
+		sessions, err := user.ListSessions(userID, sessionID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "查询失败"})
+		}
+		return c.JSON(sessions)
+	})
+
+	// 注销指定设备
+	auth.Post("/sessions/revoke", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		var data struct {
+			SessionID uint `json:"session_id"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.SessionID == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "session_id 不能为空"})
+		}
+
+		userID := c.Locals("userID").(int)
+		err := user.RevokeSession(userID, data.SessionID)
+		recordAudit(c, database.AuditSessionRevoke, database.AuditTargetSession, strconv.FormatUint(uint64(data.SessionID), 10), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "设备已下线"})
+	})
+
+	// 查询个人访问令牌
+	auth.Get("/tokens", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		tokens, err := user.ListAccessTokens(userID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "查询失败"})
+		}
+		return c.JSON(tokens)
+	})
+
+	// 创建个人访问令牌，令牌只在本次返回
+	auth.Post("/tokens", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
+		var data struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`          // 见 database.TokenScopes
+			ExpiresInDays int      `json:"expires_in_days"` // 有效天数
+		}
+		if err := c.BodyParser(&data); err != nil || data.ExpiresInDays <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "参数错误，name、scopes 和 expires_in_days 为必填项"})
+		}
+
+		userID := c.Locals("userID").(int)
+		token, info, err := user.CreateAccessToken(userID, data.Name, data.Scopes, time.Duration(data.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			recordAudit(c, database.AuditTokenCreate, database.AuditTargetToken, "", err)
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		recordAudit(c, database.AuditTokenCreate, database.AuditTargetToken, strconv.FormatUint(uint64(info.ID), 10), nil)
+		return c.JSON(fiber.Map{
+			"message": "令牌已创建，请立即保存，之后将无法再次查看",
+			"token":   token,
+			"info":    info,
+		})
+	})
+
+	// 吊销个人访问令牌
+	auth.Post("/tokens/revoke", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		var data struct {
+			TokenID uint `json:"token_id"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.TokenID == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "token_id 不能为空"})
+		}
+
+		userID := c.Locals("userID").(int)
+		err := user.RevokeAccessToken(userID, data.TokenID)
+		recordAudit(c, database.AuditTokenRevoke, database.AuditTargetToken, strconv.FormatUint(uint64(data.TokenID), 10), err)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "令牌已吊销"})
+	})
+
+	// 查询本人最近的安全记录（登录、密码与邮箱修改、绑定学号等）
+	auth.Get("/security-activity", middleware.JwtAuth, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		events, err := user.RecentSecurityActivity(userID, 50)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "查询失败"})
+		}
+		return c.JSON(events)
+	})
+
 	// 获取当前用户信息
 	auth.Get("/me", middleware.JwtAuth, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
@@ -134,15 +397,16 @@ func RegisterAuthRoutes(app *fiber.App) {
 		}
 
 		return c.JSON(fiber.Map{
-			"id":       u.ID,
-			"username": u.Username,
-			"role":     u.Role,
-			"email":    u.Email,
+			"id":        u.ID,
+			"username":  u.Username,
+			"role":      u.Role,
+			"role_name": u.Role.String(),
+			"email":     u.Email,
 		})
 	})
 
 	// 密码修改（修改成功后强制登出所有设备）
-	auth.Post("/change-password", middleware.JwtAuth, func(c *fiber.Ctx) error {
+	auth.Post("/change-password", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
 		var body struct {
 			OldPassword string `json:"old_password"`
 			NewPassword string `json:"new_password"`
@@ -159,6 +423,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 		}
 
 		if !user.CheckPasswordHash(body.OldPassword, u.Password) {
+			recordAudit(c, database.AuditPasswordChange, database.AuditTargetUser, auditUserID(userID), errors.New("原密码错误"))
 			return c.Status(400).JSON(fiber.Map{"message": "原密码错误"})
 		}
 
@@ -170,6 +435,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 		if err := user.UpdateUserPassword(u.ID, newHash); err != nil {
 			return c.Status(500).JSON(fiber.Map{"message": "密码更新失败"})
 		}
+		recordAudit(c, database.AuditPasswordChange, database.AuditTargetUser, auditUserID(userID), nil)
 
 		// ✅ 修改密码后强制所有设备下线
 		if err := user.ForceLogoutAll(u.ID); err != nil {
@@ -181,7 +447,7 @@ func RegisterAuthRoutes(app *fiber.App) {
 	})
 
 	// 邮箱修改
-	auth.Post("/change-email", middleware.JwtAuth, func(c *fiber.Ctx) error {
+	auth.Post("/change-email", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
 		var body struct {
 			NewEmail string `json:"new_email"`
 			Code     string `json:"code"`
@@ -193,23 +459,32 @@ func RegisterAuthRoutes(app *fiber.App) {
 
 		userID := c.Locals("userID").(int)
 
-		if err := user.ChangeUserEmail(userID, body.NewEmail, body.Code); err != nil {
+		err := user.ChangeUserEmail(userID, body.NewEmail, body.Code)
+		recordAudit(c, database.AuditEmailChange, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"message": err.Error()})
 		}
 
 		return c.JSON(fiber.Map{"message": "邮箱修改成功，请验证新邮箱"})
 	})
 
-	auth.Post("/send-change-email-code", func(c *fiber.Ctx) error {
+	auth.Post("/send-change-email-code", middleware.JwtAuth, middleware.RequireReauth, func(c *fiber.Ctx) error {
 		var body struct {
-			Email string `json:"email"`
+			Email        string `json:"email"`
+			PowChallenge string `json:"pow_challenge"`
+			PowNonce     string `json:"pow_nonce"`
 		}
 		if err := c.BodyParser(&body); err != nil || body.Email == "" {
 			return c.Status(400).JSON(fiber.Map{"message": "邮箱不能为空"})
 		}
 
-		if err := user.SendChangeEmailVerificationCode(body.Email); err != nil {
-			return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+		if err := user.CheckSendCodeAllowed(c.IP(), body.Email, body.PowChallenge, body.PowNonce); err != nil {
+			return c.Status(sendCodeErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		userID := c.Locals("userID").(int)
+		if err := user.SendChangeEmailVerificationCode(userID, body.Email); err != nil {
+			return c.Status(sendCodeErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
 		}
 
 		return c.JSON(fiber.Map{"message": "验证码发送成功"})
@@ -233,7 +508,9 @@ func RegisterAuthRoutes(app *fiber.App) {
 			return c.Status(401).JSON(fiber.Map{"message": "未登录"})
 		}
 
-		if err := user.UseSponsorCode(userID, body.Code); err != nil {
+		err := user.UseSponsorCode(userID, body.Code)
+		recordAudit(c, database.AuditSponsorActivate, database.AuditTargetUser, auditUserID(userID), err)
+		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"message": err.Error()})
 		}
 
@@ -241,3 +518,20 @@ func RegisterAuthRoutes(app *fiber.App) {
 	})
 
 }
+
+// deviceInfo 从请求中提取设备信息
+func deviceInfo(c *fiber.Ctx, name string) user.DeviceInfo {
+	return user.DeviceInfo{
+		Name:      name,
+		IP:        c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+}
+
+// sendCodeErrorStatus 发送配额用尽返回 429，其他检查失败返回 400
+func sendCodeErrorStatus(err error) int {
+	if errors.Is(err, user.ErrSendCodeQuota) {
+		return 429
+	}
+	return 400
+}
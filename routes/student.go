@@ -2,15 +2,20 @@
 package routes
 
 import (
+	"dormcheck/config"
 	"dormcheck/database"
+	"dormcheck/external/platform"
 	"dormcheck/logic/student"
 	"dormcheck/logic/user"
 	"dormcheck/middleware"
 	"dormcheck/utils"
+	"errors"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 )
 
 // RegisterStudentRoutes 注册与学生相关的接口路由
@@ -18,12 +23,14 @@ func RegisterStudentRoutes(app *fiber.App) {
 	studentGroup := app.Group("/student", middleware.JwtAuth)
 
 	// 用户绑定学号
-	studentGroup.Post("/bind", func(c *fiber.Ctx) error {
+	studentGroup.Post("/bind", middleware.RequireReauth, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
 
 		var data struct {
 			StuID    string `json:"stu_id"`
 			Password string `json:"password"`
+			School   string `json:"school"` // 可选，默认 swmu
+			Manual   bool   `json:"manual"` // 可选，为 true 时跳过自动识别，直接手动输入验证码
 		}
 		if err := c.BodyParser(&data); err != nil || data.StuID == "" || data.Password == "" {
 			log.Printf("绑定请求参数错误: %+v", data)
@@ -32,16 +39,157 @@ func RegisterStudentRoutes(app *fiber.App) {
 
 		log.Printf("收到用户绑定请求: 用户ID=%d, 学生ID=%s", userID, data.StuID)
 
-		if err := student.LoginAndBindStudent(userID, data.StuID, data.Password); err != nil {
+		challenge, err := student.LoginAndBindStudent(userID, data.School, data.StuID, data.Password, data.Manual)
+		if errors.Is(err, student.ErrBindPendingApproval) {
+			recordAudit(c, database.AuditStudentBindRequest, database.AuditTargetStudent, data.StuID, nil)
+			return utils.RespondJSON(c, 202, true, err.Error(), nil)
+		}
+		if errors.Is(err, student.ErrBindAttemptsExceeded) {
+			recordAudit(c, database.AuditStudentBindLocked, database.AuditTargetStudent, data.StuID, err)
+			return utils.RespondJSON(c, 429, false, err.Error(), nil)
+		}
+		if challenge == nil {
+			recordAudit(c, database.AuditStudentBind, database.AuditTargetStudent, data.StuID, err)
+		}
+		if err != nil {
+			log.Printf("绑定失败，错误信息: %v", err)
+			return utils.RespondJSON(c, 400, false, "绑定失败: "+err.Error(), nil)
+		}
+		if challenge != nil {
+			return utils.RespondJSON(c, 200, true, "请输入验证码完成绑定", challenge)
+		}
+
+		return utils.RespondJSON(c, 200, true, "绑定成功", nil)
+	})
+
+	// 提交手动输入的验证码完成绑定；验证码错误时返回新的验证码
+	studentGroup.Post("/bind/captcha", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		var data struct {
+			BindToken string `json:"bind_token"`
+			Code      string `json:"code"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.BindToken == "" || data.Code == "" {
+			return utils.RespondJSON(c, 400, false, "参数错误，bind_token 和 code 为必填项", nil)
+		}
+
+		stuID := student.BindSessionStudent(userID, data.BindToken)
+		challenge, err := student.SubmitBindCaptcha(userID, data.BindToken, data.Code)
+		if challenge == nil {
+			recordAudit(c, database.AuditStudentBind, database.AuditTargetStudent, stuID, err)
+		}
+		if err != nil {
 			log.Printf("绑定失败，错误信息: %v", err)
 			return utils.RespondJSON(c, 400, false, "绑定失败: "+err.Error(), nil)
 		}
+		if challenge != nil {
+			return utils.RespondJSON(c, 200, false, "验证码错误，请重新输入", challenge)
+		}
 
 		return utils.RespondJSON(c, 200, true, "绑定成功", nil)
 	})
 
+	// 看不清时为绑定会话换一张验证码
+	studentGroup.Post("/bind/captcha/refresh", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		var data struct {
+			BindToken string `json:"bind_token"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.BindToken == "" {
+			return utils.RespondJSON(c, 400, false, "参数错误，bind_token 不能为空", nil)
+		}
+
+		challenge, err := student.RefreshBindCaptcha(userID, data.BindToken)
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, "获取验证码失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "获取成功", challenge)
+	})
+
+	// 学校平台密码修改后更新保存的密码，无需解绑重绑
+	studentGroup.Post("/credentials", middleware.RequireReauth, middleware.RequireStudentBinding, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		var data struct {
+			StuID    string `json:"stu_id"`
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.Password == "" {
+			return utils.RespondJSON(c, 400, false, "参数错误，新密码不能为空", nil)
+		}
+
+		rearmed, err := student.UpdateStudentCredentials(userID, data.StuID, data.Password)
+		recordAudit(c, database.AuditStudentCredential, database.AuditTargetStudent, data.StuID, err)
+		if errors.Is(err, database.ErrNotStudentOwner) {
+			return utils.RespondJSON(c, 403, false, err.Error(), nil)
+		}
+		if err != nil {
+			return utils.RespondJSON(c, 400, false, "更新失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "密码已更新", fiber.Map{"rearmed_tasks": rearmed})
+	})
+
+	// 所有者查询名下学号的待处理绑定申请
+	studentGroup.Get("/bind-requests", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		reqs, err := student.ListBindRequests(userID)
+		if err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "查询成功", reqs)
+	})
+
+	// 所有者批准或拒绝绑定申请
+	decideBindRequest := func(approve bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			userID := c.Locals("userID").(int)
+
+			id, err := strconv.Atoi(c.Params("id"))
+			if err != nil || id <= 0 {
+				return utils.RespondJSON(c, 400, false, "申请ID格式错误", nil)
+			}
+
+			err = student.DecideBindRequest(userID, uint(id), approve)
+			action := database.AuditBindRequestReject
+			if approve {
+				action = database.AuditBindRequestApprove
+			}
+			recordAudit(c, action, database.AuditTargetBindRequest, strconv.Itoa(id), err)
+			if err != nil {
+				return utils.RespondJSON(c, 400, false, "处理失败: "+err.Error(), nil)
+			}
+
+			return utils.RespondJSON(c, 200, true, "处理成功", nil)
+		}
+	}
+	studentGroup.Post("/bind-requests/:id/approve", decideBindRequest(true))
+	studentGroup.Post("/bind-requests/:id/reject", decideBindRequest(false))
+
+	// 查询学号的绑定与凭据变更记录
+	studentGroup.Get("/audit", middleware.RequireStudentBinding, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		logs, err := student.ListStudentAudit(userID, c.Locals("stuID").(string))
+		if err != nil {
+			return utils.RespondJSON(c, 500, false, "查询失败: "+err.Error(), nil)
+		}
+
+		return utils.RespondJSON(c, 200, true, "查询成功", logs)
+	})
+
+	// 查询支持的学校列表
+	studentGroup.Get("/schools", func(c *fiber.Ctx) error {
+		return utils.RespondJSON(c, 200, true, "查询成功", platform.Schools())
+	})
+
 	// 用户解绑学生账号
-	studentGroup.Post("/unbind", func(c *fiber.Ctx) error {
+	studentGroup.Post("/unbind", middleware.RequireStudentBinding, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
 
 		var data struct {
@@ -60,7 +208,9 @@ func RegisterStudentRoutes(app *fiber.App) {
 			return utils.RespondJSON(c, 400, false, "解绑失败：该学号下还有未删除的签到任务，请先删除任务后再解绑", nil)
 		}
 
-		if err := user.UnbindStudent(userID, data.StuID); err != nil {
+		err = user.UnbindStudent(userID, data.StuID)
+		recordAudit(c, database.AuditStudentUnbind, database.AuditTargetStudent, data.StuID, err)
+		if err != nil {
 			return utils.RespondJSON(c, 400, false, "解绑失败: "+err.Error(), nil)
 		}
 
@@ -79,14 +229,28 @@ func RegisterStudentRoutes(app *fiber.App) {
 		return utils.RespondJSON(c, 200, true, "查询成功", binds)
 	})
 
-	// 从微学工查询指定学生的签到活动列表
-	studentGroup.Get("/activities", func(c *fiber.Ctx) error {
-		stuID := c.Query("stu_id")
-		if stuID == "" {
-			return utils.RespondJSON(c, 400, false, "缺少参数 stu_id", nil)
-		}
+	// 活动列表按用户限流：普通查询与强制刷新分别计数，保护微学工平台和学生的登录态
+	activityLimiter := limiter.New(limiter.Config{
+		Max:          config.ActivityRateLimit,
+		Expiration:   time.Minute,
+		KeyGenerator: userRateLimitKey,
+		LimitReached: rateLimitReached,
+	})
+	activityRefreshLimiter := limiter.New(limiter.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return c.Query("refresh") != "true"
+		},
+		Max:          config.ActivityRefreshLimit,
+		Expiration:   time.Minute,
+		KeyGenerator: userRateLimitKey,
+		LimitReached: rateLimitReached,
+	})
 
-		activities, err := student.GetStudentActivityList(stuID)
+	// 从微学工查询指定学生的签到活动列表（默认走缓存，refresh=true 时强制刷新）
+	studentGroup.Get("/activities", middleware.RequireStudentBinding, activityLimiter, activityRefreshLimiter, func(c *fiber.Ctx) error {
+		stuID := c.Locals("stuID").(string)
+
+		activities, err := student.GetStudentActivityList(stuID, c.Query("refresh") == "true")
 		if err != nil {
 			return utils.RespondJSON(c, 500, false, "获取活动失败: "+err.Error(), nil)
 		}
@@ -95,7 +259,7 @@ func RegisterStudentRoutes(app *fiber.App) {
 	})
 
 	// 添加签到任务
-	studentGroup.Post("/task", func(c *fiber.Ctx) error {
+	studentGroup.Post("/task", middleware.RequireStudentBinding, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
 
 		var data struct {
@@ -171,6 +335,48 @@ func RegisterStudentRoutes(app *fiber.App) {
 		return utils.RespondJSON(c, 200, true, "任务删除成功", nil)
 	})
 
+	// 立即执行一次签到任务（供脚本调用，按用户限流）
+	taskRunLimiter := limiter.New(limiter.Config{
+		Max:          config.TaskRunLimit,
+		Expiration:   time.Minute,
+		KeyGenerator: userRateLimitKey,
+		LimitReached: rateLimitReached,
+	})
+	studentGroup.Post("/task/run", taskRunLimiter, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(int)
+
+		var data struct {
+			TaskID uint `json:"task_id"`
+		}
+		if err := c.BodyParser(&data); err != nil || data.TaskID == 0 {
+			return utils.RespondJSON(c, 400, false, "参数错误，task_id 不能为空", nil)
+		}
+
+		var task database.Task
+		if err := database.DB.First(&task, data.TaskID).Error; err != nil {
+			return utils.RespondJSON(c, 404, false, "任务不存在", nil)
+		}
+		if task.UserID != userID {
+			return utils.RespondJSON(c, 403, false, "当前用户不具备操作该任务的权限！", nil)
+		}
+		if !task.Enabled {
+			return utils.RespondJSON(c, 400, false, "任务未启用", nil)
+		}
+		if task.PausedReason != "" {
+			return utils.RespondJSON(c, 400, false, "任务已暂停: "+task.PausedReason, nil)
+		}
+
+		err := student.ExecuteSignTask(&task)
+		if errors.Is(err, platform.ErrCircuitOpen) {
+			return utils.RespondJSON(c, 503, false, err.Error(), nil)
+		}
+		if err != nil {
+			return utils.RespondJSON(c, 200, false, "签到失败: "+err.Error(), task)
+		}
+
+		return utils.RespondJSON(c, 200, true, "签到成功", task)
+	})
+
 	// 查询当前用户的所有签到任务
 	studentGroup.Get("/tasks", func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(int)
@@ -184,3 +390,13 @@ func RegisterStudentRoutes(app *fiber.App) {
 	})
 
 }
+
+// userRateLimitKey 以当前登录用户作为限流键
+func userRateLimitKey(c *fiber.Ctx) string {
+	return "user:" + strconv.Itoa(c.Locals("userID").(int))
+}
+
+// rateLimitReached 触发限流时的统一响应
+func rateLimitReached(c *fiber.Ctx) error {
+	return utils.RespondJSON(c, 429, false, "请求过于频繁，请稍后再试", nil)
+}
@@ -0,0 +1,146 @@
+// routes/student_access_test.go
+package routes
+
+import (
+	"bytes"
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupStudentApp 使用内存 SQLite 初始化数据库并注册学生相关路由
+func setupStudentApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	config.JwtSecret = []byte("test-secret")
+	config.AccessTokenTTL = time.Hour
+	config.DataEncryptionKey = bytes.Repeat([]byte{1}, 32)
+	config.ActivityRateLimit = 100
+	config.ActivityRefreshLimit = 100
+	config.TaskRunLimit = 100
+	config.ReauthWindow = time.Hour
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // 内存库每个连接各自独立
+	if err := db.AutoMigrate(&database.User{}, &database.UserSession{}, &database.UserStudent{},
+		&database.Student{}, &database.StudentAuditLog{}, &database.Task{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { sqlDB.Close() })
+
+	app := fiber.New()
+	RegisterStudentRoutes(app)
+	return app
+}
+
+// createTestUser 创建用户及登录会话（已重新验证身份，避免敏感接口先被 RequireReauth 拦截），返回访问令牌
+func createTestUser(t *testing.T, id int, name string) string {
+	t.Helper()
+
+	now := time.Now()
+	u := database.User{ID: id, Username: name, Email: name + "@example.com", Password: "x"}
+	if err := database.DB.Create(&u).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	session := database.UserSession{
+		UserID:           id,
+		RefreshTokenHash: name,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+		ReverifiedAt:     &now,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+	token, err := utils.GenerateToken(u, session.ID)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	return token
+}
+
+func doRequest(t *testing.T, app *fiber.App, method, path, token string, body any) int {
+	t.Helper()
+
+	var req *http.Request
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s 请求失败: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+// 学号 X 只绑定给用户 A，用户 B 访问 X 的所有接口都应被拒绝
+func TestStudentRoutesDenyOtherUsers(t *testing.T) {
+	app := setupStudentApp(t)
+	createTestUser(t, 1, "alice")
+	tokenB := createTestUser(t, 2, "bob")
+
+	ownerID := 1
+	database.DB.Create(&database.Student{StuID: "X", Name: "张三", OwnerUserID: &ownerID})
+	database.DB.Create(&database.UserStudent{UserID: 1, StuID: "X", Name: "张三"})
+	task := database.Task{UserID: 1, StuID: "X", ActivityID: "a1", SignTime: "20:30", Enabled: true, ExecStatus: "pending"}
+	database.DB.Create(&task)
+
+	bindingCases := []struct {
+		method, path string
+		body         any
+	}{
+		{"GET", "/student/activities?stu_id=X", nil},
+		{"POST", "/student/task", map[string]any{"stu_id": "X", "activity_id": "a1", "sign_time": "20:30"}},
+		{"POST", "/student/credentials", map[string]any{"stu_id": "X", "password": "guess"}},
+		{"POST", "/student/unbind", map[string]any{"stu_id": "X"}},
+		{"GET", "/student/audit?stu_id=X", nil},
+	}
+	for _, tc := range bindingCases {
+		if code := doRequest(t, app, tc.method, tc.path, tokenB, tc.body); code != 403 {
+			t.Errorf("%s %s: 期望 403，实际 %d", tc.method, tc.path, code)
+		}
+	}
+
+	for _, path := range []string{"/student/task/run", "/student/task/delete"} {
+		if code := doRequest(t, app, "POST", path, tokenB, map[string]any{"task_id": task.ID}); code != 403 {
+			t.Errorf("POST %s: 期望 403，实际 %d", path, code)
+		}
+	}
+
+	// 拒绝后任务和绑定关系都不应被改动
+	var count int64
+	database.DB.Model(&database.Task{}).Where("id = ?", task.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("用户 B 删除了用户 A 的任务")
+	}
+	database.DB.Model(&database.UserStudent{}).Where("user_id = ? AND stu_id = ?", 1, "X").Count(&count)
+	if count != 1 {
+		t.Errorf("用户 A 的绑定被改动")
+	}
+	database.DB.Model(&database.Task{}).Where("user_id = ?", 2).Count(&count)
+	if count != 0 {
+		t.Errorf("用户 B 为未绑定的学号创建了任务")
+	}
+}
@@ -0,0 +1,25 @@
+// scheduler/code_purger.go
+package scheduler
+
+import (
+	"dormcheck/logic/user"
+	"log"
+	"time"
+)
+
+// StartVerificationCodePurger 每小时清理过期的邮箱验证码
+func StartVerificationCodePurger() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := user.PurgeExpiredVerificationCodes()
+		if err != nil {
+			log.Printf("❌ 清理过期验证码失败: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("🧹 已清理 %d 条过期验证码", n)
+		}
+	}
+}
@@ -1,22 +1,29 @@
 package scheduler
 
 import (
+	"dormcheck/captcha"
+	"dormcheck/config"
 	"dormcheck/database"
+	"dormcheck/external/platform"
 	"dormcheck/logic/student"
-	"dormcheck/utils"
+	"errors"
 	"log"
+	"sync"
 	"time"
 )
 
-// StartCookieRefresher 每天下午 18:00 更新所有学生的 cookies
+var (
+	// deferredRefresh 因平台熔断或验证码预算而推迟刷新的学号，条件恢复后补刷
+	deferredRefresh   = make(map[string]bool)
+	deferredRefreshMu sync.Mutex
+)
+
+// StartCookieRefresher 每天下午 18:00 更新所有学生的 cookies，并定期提前刷新即将失效的登录态
 func StartCookieRefresher() {
 	go func() {
 		for {
 			now := time.Now()
-			nextRefresh := time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, now.Location())
-			if now.After(nextRefresh) {
-				nextRefresh = nextRefresh.Add(24 * time.Hour)
-			}
+			nextRefresh := lastDailyRefresh(now).Add(24 * time.Hour)
 
 			time.Sleep(nextRefresh.Sub(now))
 
@@ -28,24 +35,134 @@ func StartCookieRefresher() {
 				continue
 			}
 
-			for _, stu := range students {
-				cookies, err := student.LoginWithoutBind(stu.StuID, stu.Password)
-				if err != nil {
-					log.Printf("⚠️ 登录失败: 学号=%s，错误=%v", stu.StuID, err)
-					continue
-				}
-
-				stu.Cookies = utils.SerializeCookies(cookies)
-				stu.LastLogin = time.Now()
-
-				if err := database.DB.Save(&stu).Error; err != nil {
-					log.Printf("❌ 保存失败: 学号=%s, 错误=%v", stu.StuID, err)
-				} else {
-					log.Printf("✅ 学号 %s cookies 已更新", stu.StuID)
-				}
-			}
+			// 每日例行刷新不紧急：验证码预算用完时推迟，由定时检查在预算恢复后补刷
+			refreshStudents(students, false)
 
 			log.Println("✅ 所有学生 cookies 刷新完成")
 		}
 	}()
+
+	// 每 30 分钟检查一次即将失效的登录态，并补刷推迟的学生
+	go func() {
+		ticker := time.NewTicker(30 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			now := time.Now()
+			deadline := now.Add(config.SessionRefreshAhead)
+
+			// 上次每日刷新之后已经刷新失败过的学生不再每 30 分钟重试（每次都要付费识别验证码），等下一次每日刷新
+			var students []database.Student
+			if err := database.DB.Where("session_expires_at IS NOT NULL AND session_expires_at <= ?", deadline).
+				Where("last_refresh_at IS NULL OR last_refresh_ok = ? OR last_refresh_at < ?", true, lastDailyRefresh(now)).
+				Find(&students).Error; err != nil {
+				log.Printf("❌ 查询即将失效的登录态失败: %v", err)
+				continue
+			}
+			if len(students) > 0 {
+				log.Printf("⏳ 发现 %d 个即将失效的登录态，提前刷新", len(students))
+				refreshStudents(students, true)
+			}
+
+			retryDeferredRefresh()
+		}
+	}()
+}
+
+// lastDailyRefresh 返回 now 之前最近一次每日刷新（18:00）的时间
+func lastDailyRefresh(now time.Time) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, now.Location())
+	if t.After(now) {
+		t = t.Add(-24 * time.Hour)
+	}
+	return t
+}
+
+// refreshStudents 逐个刷新学生 cookies；平台熔断或（非紧急刷新时）验证码预算用完时，记入推迟列表
+func refreshStudents(students []database.Student, urgent bool) {
+	for i := range students {
+		// 密码已失效时不再用旧密码反复登录，避免触发平台的账号锁定
+		if students[i].CredentialsInvalid {
+			continue
+		}
+		if platform.CircuitOpen(students[i].School) {
+			deferRefresh(students[i].StuID, "学校平台熔断中")
+			continue
+		}
+		if !urgent && captcha.BudgetExhausted() {
+			deferRefresh(students[i].StuID, "今日验证码识别预算已用完")
+			continue
+		}
+		if err := refreshStudentCookies(&students[i]); errors.Is(err, platform.ErrCircuitOpen) {
+			deferRefresh(students[i].StuID, "学校平台熔断中")
+		}
+	}
+}
+
+func deferRefresh(stuID, reason string) {
+	deferredRefreshMu.Lock()
+	defer deferredRefreshMu.Unlock()
+
+	if !deferredRefresh[stuID] {
+		log.Printf("⏸️ %s，学号 %s 的 cookies 刷新已推迟", reason, stuID)
+	}
+	deferredRefresh[stuID] = true
+}
+
+// retryDeferredRefresh 补刷因熔断或预算推迟的学生
+func retryDeferredRefresh() {
+	deferredRefreshMu.Lock()
+	stuIDs := make([]string, 0, len(deferredRefresh))
+	for stuID := range deferredRefresh {
+		stuIDs = append(stuIDs, stuID)
+	}
+	deferredRefresh = make(map[string]bool)
+	deferredRefreshMu.Unlock()
+
+	if len(stuIDs) == 0 {
+		return
+	}
+
+	var students []database.Student
+	if err := database.DB.Where("stu_id IN ?", stuIDs).Find(&students).Error; err != nil {
+		log.Printf("❌ 查询推迟刷新的学生失败: %v", err)
+		for _, stuID := range stuIDs {
+			deferRefresh(stuID, "查询失败")
+		}
+		return
+	}
+
+	log.Printf("🔁 补刷 %d 个推迟的学生 cookies", len(students))
+	refreshStudents(students, false)
+}
+
+// refreshStudentCookies 重新登录一个学生并保存新的 cookies；平台熔断时不记录刷新结果
+func refreshStudentCookies(stu *database.Student) error {
+	cookies, err := student.LoginWithoutBind(stu.School, stu.StuID, stu.Password, database.CaptchaSourceRefresh)
+	if errors.Is(err, platform.ErrCircuitOpen) {
+		return err
+	}
+
+	student.RecordRefreshResult(stu, err)
+	student.RecordCredentialResult(stu, err)
+	if err != nil {
+		log.Printf("⚠️ 登录失败: 学号=%s，错误=%v", stu.StuID, err)
+		if err := database.DB.Model(&database.Student{}).Where("stu_id = ?", stu.StuID).Updates(map[string]interface{}{
+			"last_refresh_at":    stu.LastRefreshAt,
+			"last_refresh_ok":    false,
+			"last_refresh_error": stu.LastRefreshError,
+		}).Error; err != nil {
+			log.Printf("❌ 保存刷新结果失败: 学号=%s, 错误=%v", stu.StuID, err)
+		}
+		return err
+	}
+
+	student.ApplySession(stu, cookies)
+
+	if err := database.DB.Save(stu).Error; err != nil {
+		log.Printf("❌ 保存失败: 学号=%s, 错误=%v", stu.StuID, err)
+		return err
+	}
+	log.Printf("✅ 学号 %s cookies 已更新", stu.StuID)
+	return nil
 }
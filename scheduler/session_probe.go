@@ -0,0 +1,38 @@
+// scheduler/session_probe.go
+package scheduler
+
+import (
+	"dormcheck/config"
+	"dormcheck/database"
+	"dormcheck/logic/student"
+	"log"
+	"time"
+)
+
+// StartSessionProbe 定期探测所有学生已保存的登录态是否仍然有效
+func StartSessionProbe() {
+	ticker := time.NewTicker(config.SessionProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var students []database.Student
+		if err := database.DB.Where("cookies <> ''").Find(&students).Error; err != nil {
+			log.Printf("❌ 查询学生失败: %v", err)
+			continue
+		}
+
+		expired := 0
+		for i := range students {
+			status := student.ProbeSession(&students[i])
+			if status == "" {
+				continue // 平台熔断中，跳过探测
+			}
+			if status == database.SessionStatusExpired {
+				expired++
+			}
+			time.Sleep(time.Second) // 逐个探测，避免集中请求平台
+		}
+
+		log.Printf("🩺 登录态探测完成：共 %d 个，已失效 %d 个", len(students), expired)
+	}
+}
@@ -3,7 +3,9 @@ package scheduler
 
 import (
 	"dormcheck/database"
+	"dormcheck/external/platform"
 	"dormcheck/logic/student"
+	"errors"
 	"log"
 	"time"
 )
@@ -35,16 +37,24 @@ func StartWorker() {
 			lastTaskCount = currentCount
 		}
 
+		deferred := 0
 		for _, task := range tasks {
 			log.Printf("→ 执行签到任务: StuID=%s, ActivityID=%s", task.StuID, task.ActivityID)
 
 			err := student.ExecuteSignTask(&task)
+			if errors.Is(err, platform.ErrCircuitOpen) {
+				deferred++
+				continue
+			}
 			if err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			} else {
 				log.Printf("✅ 执行完成（结果已由任务内部判定）: ActivityID=%s", task.ActivityID)
 			}
 		}
+		if deferred > 0 {
+			log.Printf("⏸️ 学校平台熔断中，%d 个签到任务推迟到下一轮执行", deferred)
+		}
 	}
 }
 
@@ -61,6 +71,7 @@ func GetPendingTasks() ([]database.Task, error) {
 			 exec_status != ? AND 
 			 retry_count < max_retry AND 
 			 enabled = ? AND 
+			 COALESCE(paused_reason, '') = '' AND 
 			 (executed_at IS NULL OR executed_at <= ?)`,
 			now.Format("2006-01-02 15:04:05"), // 当前时间
 			"success",
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 )
 
 // GenerateVerificationCode 生成6位数字验证码
@@ -17,3 +20,18 @@ func GenerateVerificationCode() (string, error) {
 	}
 	return string(b), nil
 }
+
+// GenerateOpaqueToken 生成 32 字节随机令牌（URL 安全的 base64）
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken 计算令牌的 SHA-256，数据库中只保存哈希
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
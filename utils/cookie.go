@@ -1,30 +1,129 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
-// SerializeCookies 将 []*http.Cookie 序列化成 HTTP 请求头 Cookie 字符串，形如：name1=value1; name2=value2（写）
+// CookieStoreVersion 当前会话存储格式版本
+const CookieStoreVersion = 1
+
+// StoredCookie 带完整属性的 cookie
+type StoredCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"` // 零值表示会话 cookie，未声明过期时间
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+// StoredSession 序列化到数据库中的登录会话
+type StoredSession struct {
+	Version    int            `json:"v"`
+	ObtainedAt time.Time      `json:"obtained_at"`
+	Cookies    []StoredCookie `json:"cookies"`
+}
+
+// SerializeCookies 将 []*http.Cookie 序列化为带版本号的 JSON，保留 Expires/Domain/Path 等属性及获取时间（写）
 func SerializeCookies(cookies []*http.Cookie) string {
-	var parts []string
+	session := StoredSession{
+		Version:    CookieStoreVersion,
+		ObtainedAt: time.Now(),
+	}
 	for _, c := range cookies {
-		parts = append(parts, c.Name+"="+c.Value)
+		session.Cookies = append(session.Cookies, StoredCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
 	}
-	return strings.Join(parts, "; ")
+
+	data, _ := json.Marshal(session)
+	return string(data)
 }
 
-// DeserializeCookies 反序列化字符串为 []*http.Cookie （读）
+// DeserializeCookies 反序列化字符串为 []*http.Cookie，兼容旧版 "name1=value1; name2=value2" 格式（读）
 func DeserializeCookies(cookieStr string) ([]*http.Cookie, error) {
+	session, err := ParseSession(cookieStr)
+	if err != nil {
+		return nil, err
+	}
+
 	var cookies []*http.Cookie
+	for _, c := range session.Cookies {
+		cookies = append(cookies, &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
+	}
+	return cookies, nil
+}
+
+// ParseSession 解析数据库中存储的会话；旧版格式没有属性和获取时间，Version 为 0
+func ParseSession(cookieStr string) (*StoredSession, error) {
+	if strings.HasPrefix(strings.TrimSpace(cookieStr), "{") {
+		var session StoredSession
+		if err := json.Unmarshal([]byte(cookieStr), &session); err != nil {
+			return nil, fmt.Errorf("会话解析失败: %v", err)
+		}
+		if session.Version > CookieStoreVersion {
+			return nil, fmt.Errorf("不支持的会话格式版本: %d", session.Version)
+		}
+		return &session, nil
+	}
+
+	// 旧版格式：name1=value1; name2=value2
+	session := &StoredSession{}
 	pairs := strings.Split(cookieStr, "; ")
 	for _, p := range pairs {
 		if parts := strings.SplitN(p, "=", 2); len(parts) == 2 {
-			cookies = append(cookies, &http.Cookie{
+			session.Cookies = append(session.Cookies, StoredCookie{
 				Name:  parts[0],
 				Value: parts[1],
 			})
 		}
 	}
-	return cookies, nil
+	return session, nil
+}
+
+// SessionExpiry 估算会话的失效时间：取所有 cookie 中最早的 Expires，
+// 并以 获取时间 + maxAge 作为上限（maxAge 为 0 时不限）。无法判断时返回 false
+func SessionExpiry(cookieStr string, maxAge time.Duration) (time.Time, bool) {
+	session, err := ParseSession(cookieStr)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var expiry time.Time
+	for _, c := range session.Cookies {
+		if c.Expires.IsZero() {
+			continue
+		}
+		if expiry.IsZero() || c.Expires.Before(expiry) {
+			expiry = c.Expires
+		}
+	}
+
+	if maxAge > 0 && !session.ObtainedAt.IsZero() {
+		limit := session.ObtainedAt.Add(maxAge)
+		if expiry.IsZero() || limit.Before(expiry) {
+			expiry = limit
+		}
+	}
+
+	return expiry, !expiry.IsZero()
 }
@@ -12,16 +12,18 @@ import (
 type Claims struct {
 	UserID       int
 	TokenVersion int
+	SessionID    uint // 对应 database.UserSession，用于单设备注销
 	jwt.RegisteredClaims
 }
 
-// 生成 JWT，内含用户ID与当前token版本
-func GenerateToken(user database.User) (string, error) {
+// 生成短期访问令牌，内含用户ID、当前token版本和设备会话ID
+func GenerateToken(user database.User, sessionID uint) (string, error) {
 	claims := Claims{
 		UserID:       user.ID,
 		TokenVersion: user.TokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)), // 30天有效期（为了保障用户体验）
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AccessTokenTTL)), // 过期后用刷新令牌换取新的访问令牌
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -30,7 +32,7 @@ func GenerateToken(user database.User) (string, error) {
 	return token.SignedString(config.JwtSecret)
 }
 
-// 验证 token 并校验 tokenVersion 是否与数据库一致
+// 验证 token，并校验 tokenVersion 是否与数据库一致、设备会话是否已注销
 func ParseToken(tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return config.JwtSecret, nil
@@ -54,5 +56,17 @@ func ParseToken(tokenStr string) (*Claims, error) {
 		return nil, errors.New("token已失效，请重新登录")
 	}
 
+	// 旧版 token 没有会话ID，需要重新登录
+	var session database.UserSession
+	if claims.SessionID == 0 ||
+		database.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", claims.SessionID, claims.UserID).First(&session).Error != nil {
+		return nil, errors.New("登录会话已失效，请重新登录")
+	}
+
+	// 最近活跃时间每分钟最多更新一次，避免每个请求都写库
+	if time.Since(session.LastSeenAt) > time.Minute {
+		database.DB.Model(&session).Update("last_seen_at", time.Now())
+	}
+
 	return claims, nil
 }
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"bytes"
+	"dormcheck/config"
 	"fmt"
 	"html/template"
+	"log"
 	"path/filepath"
 	"time"
 
@@ -83,6 +85,67 @@ func SendVerificationCodeEmail(to string, code string) error {
 	return SendMail(to, "邮箱验证", html, "", "")
 }
 
+// SendAccountLockedEmail 通知用户账号因多次登录失败被临时锁定
+func SendAccountLockedEmail(to, username, ip string, until time.Time) error {
+	html := fmt.Sprintf(`
+		<p>您好，<strong>%s</strong>：</p>
+		<p>您的账号连续多次登录失败，已被临时锁定至 <strong>%s</strong>。</p>
+		<p>最近一次失败的登录来自 IP：%s</p>
+		<p>如果不是您本人操作，建议尽快通过“忘记密码”重置密码；如需提前解锁，请联系管理员。</p>
+	`, username, until.Format("2006-01-02 15:04:05"), ip)
+	return SendMail(to, "账号安全提醒：登录已被临时锁定", html, "", "")
+}
+
+// SendBindRequestEmail 通知学号所有者有其他用户申请绑定
+func SendBindRequestEmail(to, ownerName, requesterName, stuID string) error {
+	html := fmt.Sprintf(`
+		<p>您好，<strong>%s</strong>：</p>
+		<p>用户 <strong>%s</strong> 申请绑定您所有的学号 <strong>%s</strong>。</p>
+		<p>批准后对方可以查看该学号的活动并为其创建签到任务，但不能修改登录密码。</p>
+		<p>请登录 DormCheck 在“绑定申请”中批准或拒绝；如不认识对方，请直接拒绝。</p>
+	`, ownerName, requesterName, stuID)
+	return SendMail(to, "学号绑定申请", html, "", "")
+}
+
+// SendBindDecisionEmail 通知申请人绑定申请的处理结果
+func SendBindDecisionEmail(to, requesterName, stuID string, approved bool) error {
+	result := "已被所有者拒绝"
+	if approved {
+		result = "已通过，现在可以为该学号创建签到任务"
+	}
+	html := fmt.Sprintf(`
+		<p>您好，<strong>%s</strong>：</p>
+		<p>您对学号 <strong>%s</strong> 的绑定申请%s。</p>
+	`, requesterName, stuID, result)
+	return SendMail(to, "学号绑定申请结果", html, "", "")
+}
+
+// SendCredentialsInvalidEmail 通知绑定用户学号密码已失效、任务已暂停
+func SendCredentialsInvalidEmail(to, username, stuID, stuName string, paused int64) error {
+	html := fmt.Sprintf(`
+		<p>您好，<strong>%s</strong>：</p>
+		<p>您绑定的学号 <strong>%s</strong>（%s）连续多次自动登录时被学校平台拒绝，可能已修改过密码。</p>
+		<p>该学号下的 %d 个签到任务已暂停。学号所有者更新密码后，任务会自动恢复。</p>
+	`, username, stuID, stuName, paused)
+	return SendMail(to, "学号密码已失效，签到任务已暂停", html, "", "")
+}
+
+// SendAdminAlert 向所有配置的管理员邮箱发送告警邮件
+func SendAdminAlert(subject, html string) error {
+	if len(config.AdminEmails) == 0 {
+		log.Printf("⚠️ 未配置 ADMIN_EMAILS，告警未发送: %s", subject)
+		return nil
+	}
+
+	var lastErr error
+	for _, to := range config.AdminEmails {
+		if err := SendMail(to, subject, html, "", ""); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // SendSignResultEmail 发送签到结果邮件通知
 func SendSignResultEmail(to string, stuName, activityName string, success bool, errorMsg string, sendTime time.Time) error {
 	var resultMsg string